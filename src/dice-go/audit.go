@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/rand"
+	"net"
+	"net/http"
+	"time"
+
+	olog "go.opentelemetry.io/otel/log"
+)
+
+// Attribute keys of the audit events.
+const (
+	auditRecordIDKey = "audit.record.id"
+	auditActorKey    = "audit.actor"
+	auditIssuerKey   = "audit.actor.issuer"
+	auditMethodKey   = "audit.auth.method"
+	auditClaimedKey  = "audit.player.claimed"
+	auditActionKey   = "audit.action"
+	auditOutcomeKey  = "audit.outcome"
+	auditLatencyKey  = "audit.latency_ms"
+	statusCodeKey    = "http.response.status_code"
+	clientAddressKey = "client.address"
+	userAgentKey     = "user_agent.original"
+)
+
+// auditEventName is the event name of the audit events of requests.
+const auditEventName = "audit.http.request"
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// auditMiddleware emits one audit event per request, so that every handler is audited the same way.
+// It has to run inside the HTTP instrumentation, so that the event is correlated with the trace of the request.
+func auditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		// The mux sets the pattern and the path values on r.
+		action := r.Pattern
+		if action == "" {
+			action = r.Method + " " + r.URL.Path
+		}
+		outcome, severity := "success", olog.SeverityInfo
+		if rec.status >= http.StatusBadRequest {
+			outcome, severity = "failure", olog.SeverityWarn
+		}
+		clientAddress, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			clientAddress = r.RemoteAddr
+		}
+
+		event := olog.Record{}
+		event.SetEventName(auditEventName)
+		event.SetTimestamp(start)
+		event.SetSeverity(severity)
+		event.SetBody(olog.StringValue(actor(r) + " " + action + ": " + outcome))
+		event.AddAttributes(actorAttributes(r)...)
+		event.AddAttributes(
+			olog.String(auditActionKey, action),
+			olog.String(auditOutcomeKey, outcome),
+			olog.Int(statusCodeKey, rec.status),
+			olog.String(clientAddressKey, clientAddress),
+			olog.String(userAgentKey, r.UserAgent()),
+			olog.Float64(auditLatencyKey, float64(time.Since(start).Microseconds())/1000),
+		)
+		// The trace and span IDs are taken from the context of the request.
+		logger.Emit(r.Context(), event)
+	})
+}
+
+// actor returns who performed the request: the subject verified by authMiddleware, not the player in the path,
+// which the client can choose freely.
+func actor(r *http.Request) string {
+	id, err := authenticated(r)
+	if err != nil {
+		return "unauthenticated"
+	}
+	return id.Subject
+}
+
+// actorAttributes returns the attributes of the audit records describing who performed the request.
+// The player in the path is only recorded as claimed.
+func actorAttributes(r *http.Request) []olog.KeyValue {
+	attrs := []olog.KeyValue{olog.String(auditActorKey, actor(r))}
+	if id, err := authenticated(r); err == nil {
+		attrs = append(attrs, olog.String(auditMethodKey, id.Method))
+		if id.Issuer != "" {
+			attrs = append(attrs, olog.String(auditIssuerKey, id.Issuer))
+		}
+	}
+	if player := r.PathValue("player"); player != "" {
+		attrs = append(attrs, olog.String(auditClaimedKey, player))
+	}
+	return attrs
+}
+
+// newAuditRecordID returns a random ID for an audit record, which is returned to the client with the response.
+func newAuditRecordID() string {
+	return rand.Text()
+}
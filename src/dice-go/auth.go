@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Authentication methods of an identity.
+const (
+	authMethodNone   = "none"
+	authMethodJWT    = "jwt"
+	authMethodAPIKey = "api_key"
+)
+
+// apiKeyIssuer is the issuer of the identities authenticated by an API key.
+const apiKeyIssuer = "dice-go"
+
+// errUnauthenticated is returned for requests without credentials, if authentication is required.
+var errUnauthenticated = errors.New("missing credentials")
+
+// identity is the verified identity of the client of a request.
+type identity struct {
+	Subject string
+	Issuer  string
+	Method  string
+}
+
+// anonymous is the identity of all requests if authentication is disabled.
+var anonymous = identity{Subject: "anonymous", Method: authMethodNone}
+
+type identityKey struct{}
+
+// authResult is the result of the authentication of a request, which is passed to the audit middleware and the handlers.
+type authResult struct {
+	identity identity
+	err      error
+}
+
+// authenticator verifies the credentials of requests: a JWT signed by a key of the local JWKS file
+// (Authorization: Bearer) or a static API key (X-API-Key). If neither is configured, all requests are anonymous.
+type authenticator struct {
+	keys     map[string]crypto.PublicKey
+	issuer   string
+	audience string
+	// apiKeys maps the SHA-256 of every API key to its subject, so the keys aren't compared byte by byte.
+	apiKeys map[[sha256.Size]byte]string
+}
+
+func newAuthenticator(jwksFile, issuer, audience, apiKeysFile string) (*authenticator, error) {
+	a := &authenticator{issuer: issuer, audience: audience}
+	if jwksFile != "" {
+		keys, err := loadJWKS(jwksFile)
+		if err != nil {
+			return nil, err
+		}
+		a.keys = keys
+	}
+	if apiKeysFile != "" {
+		apiKeys, err := loadAPIKeys(apiKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		a.apiKeys = apiKeys
+	}
+	return a, nil
+}
+
+// enabled reports whether requests have to be authenticated.
+func (a *authenticator) enabled() bool {
+	return a.keys != nil || a.apiKeys != nil
+}
+
+func (a *authenticator) authenticate(r *http.Request) (identity, error) {
+	if !a.enabled() {
+		return anonymous, nil
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" && a.apiKeys != nil {
+		subject, ok := a.apiKeys[sha256.Sum256([]byte(key))]
+		if !ok {
+			return identity{}, errors.New("invalid API key")
+		}
+		return identity{Subject: subject, Issuer: apiKeyIssuer, Method: authMethodAPIKey}, nil
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && a.keys != nil {
+		return a.verifyJWT(token, time.Now())
+	}
+	return identity{}, errUnauthenticated
+}
+
+// jwtHeader is the JOSE header of a JWT.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// jwtClaims are the registered claims of a JWT that are verified.
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *int64          `json:"exp"`
+	NotBefore *int64          `json:"nbf"`
+}
+
+// verifyJWT verifies the signature (RS256, ES256 or EdDSA), the expiry and, if configured, the issuer and audience of a JWT.
+func (a *authenticator) verifyJWT(token string, now time.Time) (identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return identity{}, errors.New("malformed JWT")
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return identity{}, fmt.Errorf("malformed JWT header: %w", err)
+	}
+	key, ok := a.keys[header.Kid]
+	if !ok {
+		return identity{}, fmt.Errorf("unknown JWT key %q", header.Kid)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return identity{}, fmt.Errorf("malformed JWT signature: %w", err)
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return identity{}, err
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return identity{}, fmt.Errorf("malformed JWT claims: %w", err)
+	}
+	switch {
+	case claims.Subject == "":
+		return identity{}, errors.New("JWT has no subject")
+	case claims.ExpiresAt == nil || now.Unix() >= *claims.ExpiresAt:
+		return identity{}, errors.New("JWT is expired")
+	case claims.NotBefore != nil && now.Unix() < *claims.NotBefore:
+		return identity{}, errors.New("JWT is not valid yet")
+	case a.issuer != "" && claims.Issuer != a.issuer:
+		return identity{}, fmt.Errorf("JWT has unexpected issuer %q", claims.Issuer)
+	case a.audience != "" && !hasAudience(claims.Audience, a.audience):
+		return identity{}, errors.New("JWT has unexpected audience")
+	}
+	return identity{Subject: claims.Subject, Issuer: claims.Issuer, Method: authMethodJWT}, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// hasAudience reports whether the aud claim, a string or an array of strings, contains audience.
+func hasAudience(aud json.RawMessage, audience string) bool {
+	var single string
+	if json.Unmarshal(aud, &single) == nil {
+		return single == audience
+	}
+	var multiple []string
+	if json.Unmarshal(aud, &multiple) == nil {
+		return slices.Contains(multiple, audience)
+	}
+	return false
+}
+
+func verifySignature(alg string, key crypto.PublicKey, data, signature []byte) error {
+	errInvalid := errors.New("invalid JWT signature")
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if alg != "RS256" {
+			break
+		}
+		digest := sha256.Sum256(data)
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+			return errInvalid
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if alg != "ES256" || len(signature) != 64 {
+			break
+		}
+		digest := sha256.Sum256(data)
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(key, digest[:], r, s) {
+			return errInvalid
+		}
+		return nil
+	case ed25519.PublicKey:
+		if alg != "EdDSA" {
+			break
+		}
+		if !ed25519.Verify(key, data, signature) {
+			return errInvalid
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported JWT algorithm %q for the key", alg)
+}
+
+// jwk is a public key of a JWKS file.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// loadJWKS reads the RSA, P-256 and Ed25519 public keys of a JWKS file by their key ID.
+func loadJWKS(path string) (map[string]crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS: %w", err)
+	}
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range jwks.Keys {
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWKS key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	// Without keys, authentication would be enabled, but no JWT could be verified.
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS %s has no keys", path)
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch {
+	case k.Kty == "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case k.Kty == "EC" && k.Crv == "P-256":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		// The uncompressed point encoding is validated by ParseUncompressedPublicKey.
+		return ecdsa.ParseUncompressedPublicKey(elliptic.P256(), append([]byte{4}, append(x, y...)...))
+	case k.Kty == "OKP" && k.Crv == "Ed25519":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s %s", k.Kty, k.Crv)
+	}
+}
+
+// loadAPIKeys reads a file with one "subject:key" per line. Empty lines and lines starting with # are ignored.
+func loadAPIKeys(path string) (map[[sha256.Size]byte]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API keys: %w", err)
+	}
+	apiKeys := map[[sha256.Size]byte]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		subject, key, ok := strings.Cut(line, ":")
+		if !ok || subject == "" || key == "" {
+			return nil, fmt.Errorf("invalid API key in line %d of %s", n, path)
+		}
+		apiKeys[sha256.Sum256([]byte(key))] = subject
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read API keys: %w", err)
+	}
+	if len(apiKeys) == 0 {
+		return nil, fmt.Errorf("API keys file %s has no keys", path)
+	}
+	return apiKeys, nil
+}
+
+// authMiddleware authenticates every request and passes the result on in its context. It doesn't reject requests,
+// so that the audit middleware behind it can audit failed authentications, too; requireAuth does, inside the mux.
+func authMiddleware(a *authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := a.authenticate(r)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, authResult{identity: id, err: err})))
+	})
+}
+
+// requireAuth rejects the requests that authMiddleware could not authenticate. It has to wrap the handlers inside the mux,
+// so that the mux has set the route pattern and the path values, which the audit event of a rejected request records, too.
+func requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := authenticated(r); err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticated returns the identity authMiddleware verified for r.
+func authenticated(r *http.Request) (identity, error) {
+	result, ok := r.Context().Value(identityKey{}).(authResult)
+	if !ok {
+		return identity{}, errUnauthenticated
+	}
+	return result.identity, result.err
+}
+
+// getJWKSFile retrieves the path of the JWKS file to verify JWTs with from the environment variable (AUTH_JWKS_FILE).
+// If it is empty, JWTs are not accepted.
+func getJWKSFile() string {
+	return os.Getenv("AUTH_JWKS_FILE")
+}
+
+// getJWTIssuer retrieves the required issuer of JWTs from the environment variable (AUTH_JWT_ISSUER).
+// If it is empty, any issuer is accepted.
+func getJWTIssuer() string {
+	return os.Getenv("AUTH_JWT_ISSUER")
+}
+
+// getJWTAudience retrieves the required audience of JWTs from the environment variable (AUTH_JWT_AUDIENCE).
+// If it is empty, any audience is accepted.
+func getJWTAudience() string {
+	return os.Getenv("AUTH_JWT_AUDIENCE")
+}
+
+// getAPIKeysFile retrieves the path of the file with the static API keys from the environment variable (AUTH_API_KEYS_FILE).
+// If it is empty, API keys are not accepted.
+func getAPIKeysFile() string {
+	return os.Getenv("AUTH_API_KEYS_FILE")
+}
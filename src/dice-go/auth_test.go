@@ -0,0 +1,295 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	olog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// testKeys are the private keys of the JWKS written by writeJWKS, with the key IDs rsa, ec and ed.
+type testKeys struct {
+	rsa     *rsa.PrivateKey
+	ecdsa   *ecdsa.PrivateKey
+	ed25519 ed25519.PrivateKey
+}
+
+func writeJWKS(t *testing.T) (string, testKeys) {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed25519Public, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	ecdsaPoint, err := ecdsaKey.PublicKey.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := map[string][]jwk{"keys": {
+		{Kty: "RSA", Kid: "rsa", N: b64(rsaKey.N.Bytes()), E: b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{Kty: "EC", Kid: "ec", Crv: "P-256", X: b64(ecdsaPoint[1:33]), Y: b64(ecdsaPoint[33:])},
+		{Kty: "OKP", Kid: "ed", Crv: "Ed25519", X: b64(ed25519Public)},
+	}}
+	data, err := json.Marshal(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path, testKeys{rsa: rsaKey, ecdsa: ecdsaKey, ed25519: ed25519Key}
+}
+
+// signJWT returns a JWT with the header and claims, signed with key according to alg.
+// For an unknown alg, the signature is empty.
+func signJWT(t *testing.T, header, claims map[string]any, alg string, keys testKeys) string {
+	t.Helper()
+	segment := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	input := segment(header) + "." + segment(claims)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, keys.rsa, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, keys.ecdsa, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	case "EdDSA":
+		signature = ed25519.Sign(keys.ed25519, []byte(input))
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerifyJWT(t *testing.T) {
+	jwksFile, keys := writeJWKS(t)
+	a, err := newAuthenticator(jwksFile, "https://idp.example", "dice", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	// claims returns valid claims, modified by the key-value pairs. A nil value removes the claim.
+	claims := func(kvs ...any) map[string]any {
+		c := map[string]any{
+			"sub": "alice",
+			"iss": "https://idp.example",
+			"aud": "dice",
+			"exp": now.Add(time.Hour).Unix(),
+		}
+		for i := 0; i < len(kvs); i += 2 {
+			if kvs[i+1] == nil {
+				delete(c, kvs[i].(string))
+			} else {
+				c[kvs[i].(string)] = kvs[i+1]
+			}
+		}
+		return c
+	}
+	header := func(alg, kid string) map[string]any {
+		return map[string]any{"alg": alg, "kid": kid}
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr string
+	}{
+		{name: "RS256", token: signJWT(t, header("RS256", "rsa"), claims(), "RS256", keys)},
+		{name: "ES256", token: signJWT(t, header("ES256", "ec"), claims(), "ES256", keys)},
+		{name: "EdDSA", token: signJWT(t, header("EdDSA", "ed"), claims(), "EdDSA", keys)},
+		{name: "audience list", token: signJWT(t, header("EdDSA", "ed"), claims("aud", []string{"other", "dice"}), "EdDSA", keys)},
+		{name: "valid nbf", token: signJWT(t, header("EdDSA", "ed"), claims("nbf", now.Add(-time.Minute).Unix()), "EdDSA", keys)},
+		{
+			name: "bad signature",
+			token: func() string {
+				token := signJWT(t, header("EdDSA", "ed"), claims(), "EdDSA", keys)
+				forged := signJWT(t, header("EdDSA", "ed"), claims("sub", "mallory"), "", keys)
+				// The claims of the forged token with the signature of the valid one.
+				return forged[:strings.LastIndex(forged, ".")] + token[strings.LastIndex(token, "."):]
+			}(),
+			wantErr: "invalid JWT signature",
+		},
+		{name: "alg and kty mismatch", token: signJWT(t, header("RS256", "ed"), claims(), "EdDSA", keys), wantErr: "unsupported JWT algorithm"},
+		{name: "HS256 with public key", token: signJWT(t, header("HS256", "rsa"), claims(), "", keys), wantErr: "unsupported JWT algorithm"},
+		{name: "alg none", token: signJWT(t, header("none", "ed"), claims(), "none", keys), wantErr: "unsupported JWT algorithm"},
+		{name: "unknown kid", token: signJWT(t, header("EdDSA", "other"), claims(), "EdDSA", keys), wantErr: "unknown JWT key"},
+		{name: "expired", token: signJWT(t, header("EdDSA", "ed"), claims("exp", now.Add(-time.Minute).Unix()), "EdDSA", keys), wantErr: "expired"},
+		{name: "no exp", token: signJWT(t, header("EdDSA", "ed"), claims("exp", nil), "EdDSA", keys), wantErr: "expired"},
+		{name: "future nbf", token: signJWT(t, header("EdDSA", "ed"), claims("nbf", now.Add(time.Minute).Unix()), "EdDSA", keys), wantErr: "not valid yet"},
+		{name: "wrong iss", token: signJWT(t, header("EdDSA", "ed"), claims("iss", "https://evil.example"), "EdDSA", keys), wantErr: "unexpected issuer"},
+		{name: "wrong aud", token: signJWT(t, header("EdDSA", "ed"), claims("aud", "other"), "EdDSA", keys), wantErr: "unexpected audience"},
+		{name: "no aud", token: signJWT(t, header("EdDSA", "ed"), claims("aud", nil), "EdDSA", keys), wantErr: "unexpected audience"},
+		{name: "no sub", token: signJWT(t, header("EdDSA", "ed"), claims("sub", nil), "EdDSA", keys), wantErr: "no subject"},
+		{name: "malformed", token: "not.a-jwt", wantErr: "malformed JWT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := a.verifyJWT(tt.token, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("verifyJWT() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verifyJWT() error = %v", err)
+			}
+			want := identity{Subject: "alice", Issuer: "https://idp.example", Method: authMethodJWT}
+			if id != want {
+				t.Errorf("verifyJWT() = %+v, want %+v", id, want)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	dir := t.TempDir()
+	apiKeysFile := filepath.Join(dir, "api-keys")
+	if err := os.WriteFile(apiKeysFile, []byte("# test keys\nbob:secret-bob\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	a, err := newAuthenticator("", "", "", apiKeysFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    identity
+		wantErr bool
+	}{
+		{name: "API key", headers: map[string]string{"X-API-Key": "secret-bob"}, want: identity{Subject: "bob", Issuer: apiKeyIssuer, Method: authMethodAPIKey}},
+		{name: "invalid API key", headers: map[string]string{"X-API-Key": "secret"}, wantErr: true},
+		{name: "JWT without JWKS", headers: map[string]string{"Authorization": "Bearer a.b.c"}, wantErr: true},
+		{name: "no credentials", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/rolldice/mallory", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			id, err := a.authenticate(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if id != tt.want {
+				t.Errorf("authenticate() = %+v, want %+v", id, tt.want)
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		a, err := newAuthenticator("", "", "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := a.authenticate(httptest.NewRequest("GET", "/rolldice/mallory", nil))
+		if err != nil || id != anonymous {
+			t.Errorf("authenticate() = %+v, %v, want %+v", id, err, anonymous)
+		}
+	})
+}
+
+func TestNewAuthenticatorWithoutKeys(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{name: "empty JWKS", file: "jwks.json", content: `{"keys": []}`},
+		{name: "JWKS without keys", file: "jwks.json", content: `{}`},
+		{name: "API keys file with comments only", file: "api-keys", content: "# no keys yet\n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			jwksFile, apiKeysFile := path, ""
+			if tt.file == "api-keys" {
+				jwksFile, apiKeysFile = "", path
+			}
+			if _, err := newAuthenticator(jwksFile, "", "", apiKeysFile); err == nil {
+				t.Error("newAuthenticator() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestRejectedRequestIsAudited(t *testing.T) {
+	dir := t.TempDir()
+	apiKeysFile := filepath.Join(dir, "api-keys")
+	if err := os.WriteFile(apiKeysFile, []byte("bob:secret-bob\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	a, err := newAuthenticator("", "", "", apiKeysFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &recordingProcessor{}
+	defer func(l olog.Logger) { logger = l }(logger)
+	logger = sdklog.NewLoggerProvider(sdklog.WithProcessor(recorder)).Logger("DICE_GO_SERVICE")
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/rolldice/mallory", nil)
+	r.Header.Set("X-API-Key", "secret")
+	newHTTPHandler(a).ServeHTTP(w, r)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status code = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if len(recorder.records) != 1 {
+		t.Fatalf("got %d audit events, want 1", len(recorder.records))
+	}
+	event := &recorder.records[0]
+	for key, want := range map[string]string{auditActionKey: "/rolldice/{player}", auditClaimedKey: "mallory"} {
+		if got, ok := attributeValue(event, key); !ok || got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
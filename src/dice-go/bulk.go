@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	olog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// errBulkPoolClosed is returned by bulkPool.Submit after the pool has been drained.
+var errBulkPoolClosed = errors.New("bulk log pool is closed")
+
+// errBulkQueueFull is returned by bulkPool.Submit if the records have been dropped, as the queue of the pool is full.
+var errBulkQueueFull = errors.New("bulk log queue is full")
+
+// bulkJob emits the bulk log records of a roll.
+type bulkJob struct {
+	ctx    context.Context
+	roll   int
+	player string
+	count  int
+}
+
+// bulkPool emits the bulk log records of the requests with a fixed number of workers.
+// The records are emitted with the context of their request, so they are correlated with its span, and the pool
+// counts the records that have not been emitted yet, so that they can be drained before the logger provider is shut down.
+// Submit never waits: if the queue is full, the records are dropped and counted by dice_go.log.dropped_records.
+type bulkPool struct {
+	logger  olog.Logger
+	jobs    chan bulkJob
+	dropped metric.Int64Counter
+	workers sync.WaitGroup
+	// outstanding is the number of records that have been submitted, but not emitted yet.
+	outstanding atomic.Int64
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// newBulkPool emits the records with logger, which must not be an audit logger, so that they can be rate-limited and sampled.
+// Up to queueSize jobs wait for a free worker.
+func newBulkPool(logger olog.Logger, workers, queueSize int) (*bulkPool, error) {
+	dropped, err := newDroppedRecordsCounter()
+	if err != nil {
+		return nil, err
+	}
+	p := &bulkPool{logger: logger, jobs: make(chan bulkJob, queueSize), dropped: dropped}
+	for range workers {
+		p.workers.Go(p.work)
+	}
+	return p, nil
+}
+
+// Submit queues count bulk log records of a roll. It doesn't wait for a free worker, so that the response of the request
+// doesn't depend on the bulk log records: if the queue is full, the records are dropped and errBulkQueueFull is returned.
+// The records are emitted with the values of ctx, even after the request has been canceled.
+func (p *bulkPool) Submit(ctx context.Context, roll int, player string, count int) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return errBulkPoolClosed
+	}
+
+	p.outstanding.Add(int64(count))
+	select {
+	case p.jobs <- bulkJob{ctx: context.WithoutCancel(ctx), roll: roll, player: player, count: count}:
+		return nil
+	default:
+		p.outstanding.Add(-int64(count))
+		p.dropped.Add(ctx, int64(count), metric.WithAttributes(attribute.String("reason", "queue_full")))
+		return errBulkQueueFull
+	}
+}
+
+func (p *bulkPool) work() {
+	for job := range p.jobs {
+		for i := 0; i < job.count; i++ {
+			rec := olog.Record{}
+			rec.SetSeverity(olog.SeverityInfo)
+			rec.SetBody(olog.StringValue(fmt.Sprintf("dice: %d, user: %s - bulk log message #%d", job.roll, job.player, 1+i)))
+			p.logger.Emit(job.ctx, rec)
+			p.outstanding.Add(-1)
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+// Outstanding returns the number of records that have been submitted, but not emitted yet.
+func (p *bulkPool) Outstanding() int64 {
+	return p.outstanding.Load()
+}
+
+// Drain stops accepting jobs and waits until the queued records have been emitted or ctx is done.
+// It must not be called before the HTTP server has been shut down, as later Submits fail.
+func (p *bulkPool) Drain(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d bulk log records not emitted: %w", p.Outstanding(), ctx.Err())
+	}
+}
+
+const defaultBulkWorkers = 4
+
+// getBulkWorkers retrieves the number of workers emitting the bulk log records from the environment variable.
+// If the variable (BULK_LOG_WORKERS) is not set or invalid, it returns the default number.
+func getBulkWorkers() int {
+	if val, ok := os.LookupEnv("BULK_LOG_WORKERS"); ok {
+		if n, err := strconv.Atoi(val); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultBulkWorkers
+}
+
+const defaultBulkQueueSize = 100
+
+// getBulkQueueSize retrieves the number of rolls whose bulk log records can wait for a worker from the environment variable.
+// If the variable (BULK_LOG_QUEUE_SIZE) is not set or invalid, it returns the default size.
+func getBulkQueueSize() int {
+	if val, ok := os.LookupEnv("BULK_LOG_QUEUE_SIZE"); ok {
+		if n, err := strconv.Atoi(val); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return defaultBulkQueueSize
+}
+
+const defaultBulkDrainTimeout = 30 * time.Second
+
+// getBulkDrainTimeout retrieves how long the shutdown waits for the bulk log records from the environment variable.
+// If the variable (BULK_LOG_DRAIN_TIMEOUT) is not set or invalid, it returns the default timeout.
+func getBulkDrainTimeout() time.Duration {
+	if val, ok := os.LookupEnv("BULK_LOG_DRAIN_TIMEOUT"); ok {
+		if d, err := time.ParseDuration(val); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultBulkDrainTimeout
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	olog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/noop"
+)
+
+// blockingLogger blocks every Emit until release is closed, signalling emitting before.
+type blockingLogger struct {
+	noop.Logger
+	emitting chan struct{}
+	release  chan struct{}
+}
+
+func (l *blockingLogger) Emit(context.Context, olog.Record) {
+	l.emitting <- struct{}{}
+	<-l.release
+}
+
+func TestBulkPoolSubmitDoesNotWait(t *testing.T) {
+	logger := &blockingLogger{emitting: make(chan struct{}, 10), release: make(chan struct{})}
+	p, err := newBulkPool(logger, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first job keeps the worker busy, the second one waits in the queue.
+	if err := p.Submit(context.Background(), 42, "bob", 1); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	<-logger.emitting
+	if err := p.Submit(context.Background(), 42, "bob", 1); err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if err := p.Submit(context.Background(), 42, "bob", 1); !errors.Is(err, errBulkQueueFull) {
+		t.Fatalf("Submit() error = %v, want %v", err, errBulkQueueFull)
+	}
+	if got := p.Outstanding(); got != 2 {
+		t.Errorf("Outstanding() = %d, want 2", got)
+	}
+
+	close(logger.release)
+	if err := p.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain() error = %v", err)
+	}
+	if got := p.Outstanding(); got != 0 {
+		t.Errorf("Outstanding() after Drain() = %d, want 0", got)
+	}
+	if err := p.Submit(context.Background(), 42, "bob", 1); !errors.Is(err, errBulkPoolClosed) {
+		t.Errorf("Submit() after Drain() error = %v, want %v", err, errBulkPoolClosed)
+	}
+}
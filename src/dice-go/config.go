@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/contrib/otelconf"
+)
+
+// newConfiguredSDK creates the OpenTelemetry SDK from the declarative configuration file named by OTEL_CONFIG_FILE,
+// see https://opentelemetry.io/docs/specs/otel/configuration/data-model/.
+// The exporters, processors, resource and limits of the logger provider are all taken from the file.
+func newConfiguredSDK(ctx context.Context) (otelconf.SDK, error) {
+	sdk, err := otelconf.NewSDK(otelconf.WithContext(ctx))
+	if err != nil {
+		return sdk, fmt.Errorf("failed to load %s: %w", getConfigFile(), err)
+	}
+	return sdk, nil
+}
+
+// getConfigFile retrieves the path of the declarative configuration file from the environment variable.
+// If the variable (OTEL_CONFIG_FILE) is not set, it returns an empty string and the built-in pipeline is used.
+func getConfigFile() string {
+	return os.Getenv("OTEL_CONFIG_FILE")
+}
+
+// auditGuaranteeVariables are the environment variables of the guarantees of the built-in audit pipeline,
+// which the logger provider of a configuration file does not provide.
+var auditGuaranteeVariables = []string{
+	"AUDIT_WAL_DIR",
+	"AUDIT_SPOOL_FILE",
+	"AUDIT_DEAD_LETTER_FILE",
+	"AUDIT_SIGNING_KEY_FILE",
+	"PRODUCER_EPOCH_FILE",
+}
+
+// checkConfigFileGuarantees returns an error if audit guarantees are requested together with a configuration file, by
+// AUDIT_DELIVERY_MODE=sync or one of the auditGuaranteeVariables, as the service would silently run without them.
+// Otherwise it warns that the audit records are neither durable nor confirmed.
+func checkConfigFileGuarantees(configFile string) error {
+	var requested []string
+	if os.Getenv("AUDIT_DELIVERY_MODE") == "sync" {
+		requested = append(requested, "AUDIT_DELIVERY_MODE=sync")
+	}
+	for _, name := range auditGuaranteeVariables {
+		if os.Getenv(name) != "" {
+			requested = append(requested, name)
+		}
+	}
+	if len(requested) > 0 {
+		return fmt.Errorf("%s does not support the audit guarantees requested by %s", configFile, strings.Join(requested, ", "))
+	}
+	log.Printf("WARNING: %s provides no audit guarantees: audit records are not written to a write-ahead log, "+
+		"not failed over, not signed, not sequenced and their delivery is not confirmed\n", configFile)
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetupOTelSDKConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := os.WriteFile(invalid, []byte("file_format: [\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "missing file", env: map[string]string{"OTEL_CONFIG_FILE": filepath.Join(dir, "missing.yaml")}, wantErr: "failed to load"},
+		{name: "invalid file", env: map[string]string{"OTEL_CONFIG_FILE": invalid}, wantErr: "failed to load"},
+		{
+			name:    "must-deliver records",
+			env:     map[string]string{"OTEL_CONFIG_FILE": "otel-config.yaml", "AUDIT_DELIVERY_MODE": "sync"},
+			wantErr: "AUDIT_DELIVERY_MODE=sync",
+		},
+		{
+			name:    "write-ahead log and signing",
+			env:     map[string]string{"OTEL_CONFIG_FILE": "otel-config.yaml", "AUDIT_WAL_DIR": dir, "AUDIT_SIGNING_KEY_FILE": "key.pem"},
+			wantErr: "AUDIT_WAL_DIR, AUDIT_SIGNING_KEY_FILE",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			shutdown, err := setupOTelSDK(context.Background())
+			if err == nil {
+				_ = shutdown(context.Background())
+				t.Fatal("setupOTelSDK() succeeded")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("setupOTelSDK() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	olog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// errNotDelivered is returned by auditLogger.Emit if no exporter has exported the record.
+var errNotDelivered = errors.New("audit record was not exported")
+
+// deliveryResultKey is the context key of the deliveryResult of a record emitted by an auditLogger.
+type deliveryResultKey struct{}
+
+// deliveryStatus tells callers of an auditLogger what happened to their audit record.
+type deliveryStatus string
+
+const (
+	// statusDelivered means the record has been exported to a collector.
+	statusDelivered deliveryStatus = "delivered"
+	// statusQueued means the record has been stored locally, but not exported to a collector (yet).
+	statusQueued deliveryStatus = "queued"
+	// statusFailed means the record could not be exported.
+	statusFailed deliveryStatus = "failed"
+)
+
+// deliveryResult collects the export results of a record.
+type deliveryResult struct {
+	mu        sync.Mutex
+	delivered int
+	queued    bool
+	err       error
+}
+
+// mustDeliver reports whether the record emitted with ctx has been emitted by a waiting auditLogger,
+// which refuses the action if the record is not exported.
+func mustDeliver(ctx context.Context) bool {
+	_, ok := ctx.Value(deliveryResultKey{}).(*deliveryResult)
+	return ok
+}
+
+// add records the result of an export. A record that has only been spooled is queued, not failed,
+// as the write-ahead log keeps it until it has been exported to a collector.
+func (r *deliveryResult) add(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if errors.Is(err, errSpooled) {
+		r.queued = true
+		r.delivered++
+	} else if err != nil {
+		r.err = errors.Join(r.err, err)
+	} else {
+		r.delivered++
+	}
+}
+
+// deliveryProcessor exports every record synchronously like the SimpleProcessor, but reports the export result
+// to the auditLogger that emitted the record. Export errors of other records are passed to the OTel error handler.
+type deliveryProcessor struct {
+	mu       sync.Mutex
+	exporter sdklog.Exporter
+}
+
+var _ sdklog.Processor = (*deliveryProcessor)(nil)
+
+func newDeliveryProcessor(exporter sdklog.Exporter) *deliveryProcessor {
+	return &deliveryProcessor{exporter: exporter}
+}
+
+// Enabled returns true, as all records are exported.
+func (*deliveryProcessor) Enabled(context.Context, sdklog.EnabledParameters) bool {
+	return true
+}
+
+func (p *deliveryProcessor) OnEmit(ctx context.Context, rec *sdklog.Record) error {
+	p.mu.Lock()
+	err := p.exporter.Export(ctx, []sdklog.Record{*rec})
+	p.mu.Unlock()
+
+	if result, ok := ctx.Value(deliveryResultKey{}).(*deliveryResult); ok {
+		result.add(err)
+	} else if err != nil && !errors.Is(err, errSpooled) {
+		otel.Handle(err)
+	}
+	return nil
+}
+
+func (p *deliveryProcessor) Shutdown(ctx context.Context) error {
+	return p.exporter.Shutdown(ctx)
+}
+
+func (p *deliveryProcessor) ForceFlush(ctx context.Context) error {
+	return p.exporter.ForceFlush(ctx)
+}
+
+// auditLogger emits "must-deliver" audit records. Unlike olog.Logger, Emit waits until the record has been exported
+// and returns an error if it could not be, so that the action can be refused ("no audit, no action").
+// The records have to be exported by a deliveryProcessor.
+//
+// In async mode, Emit doesn't wait and the status of every record is queued.
+type auditLogger struct {
+	logger  olog.Logger
+	timeout time.Duration
+	async   bool
+}
+
+func newAuditLogger(logger olog.Logger, timeout time.Duration, async bool) *auditLogger {
+	return &auditLogger{logger: logger, timeout: timeout, async: async}
+}
+
+// Emit emits rec and returns an error if any deliveryProcessor failed to export it within the timeout.
+func (l *auditLogger) Emit(ctx context.Context, rec olog.Record) (deliveryStatus, error) {
+	if l.async {
+		go l.logger.Emit(context.WithoutCancel(ctx), rec)
+		return statusQueued, nil
+	}
+
+	result := &deliveryResult{}
+	ctx, cancel := context.WithTimeout(context.WithValue(ctx, deliveryResultKey{}, result), l.timeout)
+	defer cancel()
+
+	l.logger.Emit(ctx, rec)
+
+	result.mu.Lock()
+	defer result.mu.Unlock()
+	switch {
+	case result.err != nil:
+		return statusFailed, result.err
+	case result.delivered == 0:
+		return statusFailed, errNotDelivered
+	case result.queued:
+		return statusQueued, nil
+	default:
+		return statusDelivered, nil
+	}
+}
+
+const defaultAuditTimeout = 5 * time.Second
+
+// getAuditTimeout retrieves the timeout for the delivery of audit records from the environment variable.
+// If the variable (AUDIT_DELIVERY_TIMEOUT) is not set or invalid, it returns the default timeout.
+func getAuditTimeout() time.Duration {
+	if val, ok := os.LookupEnv("AUDIT_DELIVERY_TIMEOUT"); ok {
+		if d, err := time.ParseDuration(val); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultAuditTimeout
+}
+
+// getAuditAsync reports whether audit records are emitted without waiting for their delivery.
+// It is enabled by setting the environment variable AUDIT_DELIVERY_MODE to "async". The default mode is "sync".
+func getAuditAsync() bool {
+	return os.Getenv("AUDIT_DELIVERY_MODE") == "async"
+}
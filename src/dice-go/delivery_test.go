@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	olog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// fakeExporter records the exported records and returns err. With a delay, Export waits until the delay has passed
+// or its context is done.
+type fakeExporter struct {
+	mu    sync.Mutex
+	err   error
+	delay time.Duration
+	// records are the exported records, mustDeliver tells whether they have been exported for a waiting auditLogger.
+	records     []sdklog.Record
+	mustDeliver []bool
+	shutdown    bool
+}
+
+func (e *fakeExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	if e.delay > 0 {
+		select {
+		case <-time.After(e.delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, rec := range records {
+		e.records = append(e.records, rec.Clone())
+		e.mustDeliver = append(e.mustDeliver, mustDeliver(ctx))
+	}
+	return e.err
+}
+
+func (e *fakeExporter) setErr(err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.err = err
+}
+
+func (e *fakeExporter) exported() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.records)
+}
+
+func (e *fakeExporter) Shutdown(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.shutdown = true
+	return nil
+}
+
+func (e *fakeExporter) ForceFlush(context.Context) error { return nil }
+
+func TestAuditLogger(t *testing.T) {
+	errExport := errors.New("collector unavailable")
+
+	tests := []struct {
+		name      string
+		exporter  *fakeExporter
+		async     bool
+		noProc    bool
+		want      deliveryStatus
+		wantErr   error
+		wantCount int
+	}{
+		{name: "delivered", exporter: &fakeExporter{}, want: statusDelivered, wantCount: 1},
+		{name: "spooled", exporter: &fakeExporter{err: errors.Join(errSpooled, errExport)}, want: statusQueued, wantCount: 1},
+		{name: "failed", exporter: &fakeExporter{err: errExport}, want: statusFailed, wantErr: errExport, wantCount: 1},
+		{name: "timeout", exporter: &fakeExporter{delay: time.Minute}, want: statusFailed, wantErr: context.DeadlineExceeded},
+		{name: "no delivery processor", exporter: &fakeExporter{}, noProc: true, want: statusFailed, wantErr: errNotDelivered, wantCount: 1},
+		{name: "async", exporter: &fakeExporter{err: errExport}, async: true, want: statusQueued},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []sdklog.LoggerProviderOption
+			if tt.noProc {
+				opts = append(opts, sdklog.WithProcessor(sdklog.NewSimpleProcessor(tt.exporter)))
+			} else {
+				opts = append(opts, sdklog.WithProcessor(newDeliveryProcessor(tt.exporter)))
+			}
+			provider := sdklog.NewLoggerProvider(opts...)
+			l := newAuditLogger(provider.Logger("DICE_GO_SERVICE"), 50*time.Millisecond, tt.async)
+
+			rec := olog.Record{}
+			rec.SetBody(olog.StringValue("bob is rolling the dice"))
+			status, err := l.Emit(context.Background(), rec)
+			if status != tt.want {
+				t.Errorf("Emit() status = %q, want %q", status, tt.want)
+			}
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Emit() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.async {
+				return
+			}
+			if got := tt.exporter.exported(); got != tt.wantCount {
+				t.Fatalf("exported %d records, want %d", got, tt.wantCount)
+			}
+			if tt.wantCount > 0 && !tt.noProc && !tt.exporter.mustDeliver[0] {
+				t.Error("record has not been exported as must-deliver")
+			}
+		})
+	}
+
+	t.Run("ordinary logger", func(t *testing.T) {
+		// The records of other loggers are not must-deliver, so the write-ahead log replays them instead of refusing them.
+		exporter := &fakeExporter{err: errExport}
+		provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(newDeliveryProcessor(exporter)))
+		provider.Logger("dice-go/bulk").Emit(context.Background(), olog.Record{})
+		if exporter.exported() != 1 || exporter.mustDeliver[0] {
+			t.Errorf("exported %d records, mustDeliver = %v, want 1 ordinary record", exporter.exported(), exporter.mustDeliver)
+		}
+	})
+}
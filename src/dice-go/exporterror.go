@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// ExportErrorHandler handles errors that occur during log record export, as proposed in docs/golang-client.md.
+// Unlike otel.ErrorHandler, it receives the records that failed to export.
+type ExportErrorHandler interface {
+	// HandleExportError is called when an export operation fails.
+	// records contains the log records that failed to export.
+	// err is the error that caused the export to fail.
+	HandleExportError(ctx context.Context, records []sdklog.Record, err error)
+}
+
+// ExportErrorHandlerFunc is a convenience adapter to allow the use of a function
+// as an ExportErrorHandler.
+type ExportErrorHandlerFunc func(ctx context.Context, records []sdklog.Record, err error)
+
+var _ ExportErrorHandler = ExportErrorHandlerFunc(nil)
+
+// HandleExportError handles the export error by calling the ExportErrorHandlerFunc itself.
+func (f ExportErrorHandlerFunc) HandleExportError(ctx context.Context, records []sdklog.Record, err error) {
+	f(ctx, records, err)
+}
+
+// ExportErrorContext provides context for export errors including metadata.
+type ExportErrorContext struct {
+	ProcessorType string         // "batch" or "simple"
+	ExporterName  string         // Name/type of the exporter
+	RetryAttempt  int            // Current retry attempt (if applicable)
+	Metadata      map[string]any // Additional context data
+}
+
+// EnhancedExportErrorHandler provides more detailed error context.
+type EnhancedExportErrorHandler interface {
+	// HandleExportErrorWithContext is called when an export operation fails with additional context.
+	HandleExportErrorWithContext(ctx context.Context, records []sdklog.Record, err error, errorCtx ExportErrorContext)
+}
+
+// handleExportError passes the error to the EnhancedExportErrorHandler of handler, if it has one.
+func handleExportError(ctx context.Context, handler ExportErrorHandler, records []sdklog.Record, err error, errorCtx ExportErrorContext) {
+	if enhanced, ok := handler.(EnhancedExportErrorHandler); ok {
+		enhanced.HandleExportErrorWithContext(ctx, records, err, errorCtx)
+		return
+	}
+	handler.HandleExportError(ctx, records, err)
+}
+
+// exportErrorHandlers combines multiple handlers into one.
+type exportErrorHandlers []ExportErrorHandler
+
+var (
+	_ ExportErrorHandler         = exportErrorHandlers(nil)
+	_ EnhancedExportErrorHandler = exportErrorHandlers(nil)
+)
+
+func (h exportErrorHandlers) HandleExportError(ctx context.Context, records []sdklog.Record, err error) {
+	for _, handler := range h {
+		handler.HandleExportError(ctx, records, err)
+	}
+}
+
+func (h exportErrorHandlers) HandleExportErrorWithContext(ctx context.Context, records []sdklog.Record, err error, errorCtx ExportErrorContext) {
+	for _, handler := range h {
+		handleExportError(ctx, handler, records, err, errorCtx)
+	}
+}
+
+// errorHandlingExporter passes the records of failed exports to an ExportErrorHandler.
+// Records replayed by the write-ahead log have already been passed to the handler when their first export failed,
+// so the handler gets every failed record once, however often its export is retried.
+type errorHandlingExporter struct {
+	sdklog.Exporter
+	name    string
+	handler ExportErrorHandler
+}
+
+func newErrorHandlingExporter(exporter sdklog.Exporter, name string, handler ExportErrorHandler) *errorHandlingExporter {
+	return &errorHandlingExporter{Exporter: exporter, name: name, handler: handler}
+}
+
+func (e *errorHandlingExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	err := e.Exporter.Export(ctx, records)
+	// Spooled records are not lost, the write-ahead log retries them.
+	if err != nil && !errors.Is(err, errSpooled) && !isReplay(ctx) {
+		// The records are exported synchronously by the deliveryProcessor.
+		handleExportError(ctx, e.handler, records, err, ExportErrorContext{ProcessorType: "simple", ExporterName: e.name})
+	}
+	return err
+}
+
+// deadLetterFile appends the records of failed exports to a file, one JSON object per failed export.
+// The records are kept in the same format as in the write-ahead log.
+type deadLetterFile struct {
+	mu   sync.Mutex
+	path string
+}
+
+// deadLetter is a line of the dead-letter file.
+type deadLetter struct {
+	Time     time.Time   `json:"time"`
+	Exporter string      `json:"exporter"`
+	Error    string      `json:"error"`
+	Records  []walRecord `json:"records"`
+}
+
+func newDeadLetterFile(path string) *deadLetterFile {
+	return &deadLetterFile{path: path}
+}
+
+var _ EnhancedExportErrorHandler = (*deadLetterFile)(nil)
+
+func (d *deadLetterFile) HandleExportError(ctx context.Context, records []sdklog.Record, err error) {
+	d.HandleExportErrorWithContext(ctx, records, err, ExportErrorContext{})
+}
+
+func (d *deadLetterFile) HandleExportErrorWithContext(_ context.Context, records []sdklog.Record, err error, errorCtx ExportErrorContext) {
+	letter := deadLetter{Time: time.Now(), Exporter: errorCtx.ExporterName, Error: err.Error()}
+	for i := range records {
+		letter.Records = append(letter.Records, toWALRecord(&records[i]))
+	}
+	line, mErr := json.Marshal(letter)
+	if mErr != nil {
+		otel.Handle(fmt.Errorf("failed to encode dead letter: %w", mErr))
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	f, oErr := os.OpenFile(d.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if oErr != nil {
+		otel.Handle(fmt.Errorf("failed to open dead-letter file: %w", oErr))
+		return
+	}
+	defer f.Close()
+	if _, wErr := f.Write(append(line, '\n')); wErr != nil {
+		otel.Handle(fmt.Errorf("failed to write dead letter: %w", wErr))
+	}
+}
+
+// failedRecordsCounter counts the records of failed exports per exporter.
+// It uses the global MeterProvider, so it is a no-op until one is registered.
+type failedRecordsCounter struct {
+	counter metric.Int64Counter
+}
+
+func newFailedRecordsCounter() (*failedRecordsCounter, error) {
+	counter, err := otel.Meter("dice-go").Int64Counter("dice_go.log.export.failed_records",
+		metric.WithDescription("Number of log records that failed to export."),
+		metric.WithUnit("{record}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &failedRecordsCounter{counter: counter}, nil
+}
+
+var _ EnhancedExportErrorHandler = (*failedRecordsCounter)(nil)
+
+func (c *failedRecordsCounter) HandleExportError(ctx context.Context, records []sdklog.Record, err error) {
+	c.HandleExportErrorWithContext(ctx, records, err, ExportErrorContext{})
+}
+
+func (c *failedRecordsCounter) HandleExportErrorWithContext(ctx context.Context, records []sdklog.Record, _ error, errorCtx ExportErrorContext) {
+	// The context of the export may already be canceled, which doesn't matter for the counter.
+	c.counter.Add(context.WithoutCancel(ctx), int64(len(records)), metric.WithAttributes(attribute.String("exporter", errorCtx.ExporterName)))
+}
+
+const defaultDeadLetterFile = "dead-letter.jsonl"
+
+// getDeadLetterFile retrieves the path of the dead-letter file from the environment variable.
+// If the variable (AUDIT_DEAD_LETTER_FILE) is not set, it returns the default path.
+func getDeadLetterFile() string {
+	if val, ok := os.LookupEnv("AUDIT_DEAD_LETTER_FILE"); ok && val != "" {
+		return val
+	}
+	return defaultDeadLetterFile
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// errSpooled is returned by failoverExporter.Export if the records have only been stored by a local backend.
+// They have not been exported to a collector, so they have to be retried, but they are not lost either.
+var errSpooled = errors.New("records have only been stored locally")
+
+// failoverExporter exports the records with the first healthy backend, in order of preference.
+// A backend that fails is skipped for the retry interval and then tried again, so that the export
+// switches back to the primary backend automatically once it has recovered.
+type failoverExporter struct {
+	backends      []*backend
+	retryInterval time.Duration
+
+	mu sync.Mutex
+	// failingSince is the time of the first of the exports that did not reach a collector since the last one that did.
+	// Records that have only been spooled count as failed, as the spool file is only a fallback.
+	failingSince time.Time
+	// lastDelivered is the time of the last export that reached a collector.
+	lastDelivered time.Time
+	// spooling is set if the last records have only been stored by a local backend.
+	spooling bool
+}
+
+// backend is an exporter of a failoverExporter with its health.
+type backend struct {
+	name     string
+	exporter sdklog.Exporter
+	// local is set if the backend only stores the records locally instead of sending them to a collector.
+	local bool
+	// probe checks whether the collector of the backend can be reached without exporting records. It is nil for local backends.
+	probe func(context.Context) error
+
+	mu          sync.Mutex
+	healthy     bool
+	retryAt     time.Time
+	successes   int
+	failures    int
+	lastError   string
+	lastFailure time.Time
+}
+
+// backendStatus is the health of a backend.
+type backendStatus struct {
+	Name        string    `json:"name"`
+	Healthy     bool      `json:"healthy"`
+	Successes   int       `json:"successes"`
+	Failures    int       `json:"failures"`
+	LastError   string    `json:"lastError,omitempty"`
+	LastFailure time.Time `json:"lastFailure,omitzero"`
+}
+
+var _ sdklog.Exporter = (*failoverExporter)(nil)
+
+func newFailoverExporter(retryInterval time.Duration, backends ...*backend) *failoverExporter {
+	return &failoverExporter{backends: backends, retryInterval: retryInterval}
+}
+
+func newBackend(name string, exporter sdklog.Exporter) *backend {
+	return &backend{name: name, exporter: exporter, healthy: true}
+}
+
+// newCollectorBackend returns a backend that exports the records to the collector at endpoint, which is probed by connecting to it.
+func newCollectorBackend(name string, exporter sdklog.Exporter, endpoint string) *backend {
+	b := newBackend(name, exporter)
+	b.probe = dialProbe(endpoint)
+	return b
+}
+
+// newLocalBackend returns a backend that stores the records locally.
+func newLocalBackend(name string, exporter sdklog.Exporter) *backend {
+	b := newBackend(name, exporter)
+	b.local = true
+	return b
+}
+
+// Export tries the backends in order until one of them has exported the records.
+// It returns errSpooled if only a local backend has stored them.
+func (f *failoverExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	err := f.export(ctx, records)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case err == nil:
+		f.failingSince = time.Time{}
+		f.lastDelivered = time.Now()
+		f.spooling = false
+	case errors.Is(err, errSpooled):
+		f.spooling = true
+	}
+	if err != nil && f.failingSince.IsZero() {
+		f.failingSince = time.Now()
+	}
+	return err
+}
+
+func (f *failoverExporter) export(ctx context.Context, records []sdklog.Record) error {
+	var errs []error
+	for _, b := range f.backends {
+		// Replayed records have already been stored locally when their first export failed.
+		if !b.available() || b.local && isReplay(ctx) {
+			continue
+		}
+		err := b.exporter.Export(ctx, records)
+		b.record(err, f.retryInterval)
+		if err == nil {
+			if b.local {
+				return errors.Join(append([]error{errSpooled}, errs...)...)
+			}
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", b.name, err))
+	}
+	if len(errs) == 0 {
+		return errors.New("no backend available")
+	}
+	return errors.Join(errs...)
+}
+
+func (f *failoverExporter) Shutdown(ctx context.Context) error {
+	var err error
+	for _, b := range f.backends {
+		err = errors.Join(err, b.exporter.Shutdown(ctx))
+	}
+	return err
+}
+
+func (f *failoverExporter) ForceFlush(ctx context.Context) error {
+	var err error
+	for _, b := range f.backends {
+		err = errors.Join(err, b.exporter.ForceFlush(ctx))
+	}
+	return err
+}
+
+// probe checks whether a collector can be reached again, while the exports are failing. The OTLP exporters don't send
+// empty exports, so the backends are probed by connecting to their collectors instead. The first reachable backend
+// is marked healthy, so that the next export tries it, and the failure is cleared, so that the service gets ready
+// again without waiting for records that happen to be exported.
+func (f *failoverExporter) probe(ctx context.Context) bool {
+	for _, b := range f.backends {
+		if b.probe == nil || b.probe(ctx) != nil {
+			continue
+		}
+		b.recovered()
+		f.mu.Lock()
+		f.failingSince = time.Time{}
+		f.mu.Unlock()
+		return true
+	}
+	return false
+}
+
+// status returns the health of the backends.
+func (f *failoverExporter) status() []backendStatus {
+	statuses := make([]backendStatus, 0, len(f.backends))
+	for _, b := range f.backends {
+		statuses = append(statuses, b.status())
+	}
+	return statuses
+}
+
+// failingFor returns how long the exports have not reached a collector, or 0 if the last export did.
+func (f *failoverExporter) failingFor() time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failingSince.IsZero() {
+		return 0
+	}
+	return time.Since(f.failingSince)
+}
+
+// delivery returns the time of the last export that reached a collector and whether the last records have only been spooled.
+func (f *failoverExporter) delivery() (lastDelivered time.Time, spooling bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lastDelivered, f.spooling
+}
+
+// available reports whether the backend is healthy or its retry interval has elapsed.
+func (b *backend) available() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.healthy || !time.Now().Before(b.retryAt)
+}
+
+func (b *backend) record(err error, retryInterval time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		if !b.healthy {
+			log.Printf("Log backend %s recovered\n", b.name)
+		}
+		b.healthy = true
+		b.successes++
+		return
+	}
+	if b.healthy {
+		log.Printf("Log backend %s failed, retrying in %v: %v\n", b.name, retryInterval, err)
+	}
+	b.healthy = false
+	b.retryAt = time.Now().Add(retryInterval)
+	b.failures++
+	b.lastError = err.Error()
+	b.lastFailure = time.Now()
+}
+
+// recovered marks the backend healthy without an export.
+func (b *backend) recovered() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.healthy {
+		log.Printf("Log backend %s is reachable again\n", b.name)
+	}
+	b.healthy = true
+}
+
+func (b *backend) status() backendStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return backendStatus{
+		Name:        b.name,
+		Healthy:     b.healthy,
+		Successes:   b.successes,
+		Failures:    b.failures,
+		LastError:   b.lastError,
+		LastFailure: b.lastFailure,
+	}
+}
+
+// spoolExporter appends the records to a local file, one JSON object per record in the same format as in the
+// write-ahead log. It is the last resort of the failoverExporter when no collector can be reached.
+//
+// The spool file is only a local copy, the write-ahead log keeps the records until they have reached a collector.
+// So instead of growing without bound, the file is rotated once it would exceed maxSize: it is renamed to
+// path.1, replacing the previous one, and a new file is started.
+type spoolExporter struct {
+	path    string
+	maxSize int64
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	closed bool
+}
+
+var _ sdklog.Exporter = (*spoolExporter)(nil)
+
+func newSpoolExporter(path string, maxSize int64) (*spoolExporter, error) {
+	s := &spoolExporter{path: path, maxSize: maxSize}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *spoolExporter) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open spool file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open spool file: %w", err)
+	}
+	s.file = f
+	s.size = info.Size()
+	return nil
+}
+
+// rotate renames the spool file to path.1 and starts a new one.
+func (s *spoolExporter) rotate() error {
+	err := s.file.Close()
+	s.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to rotate spool file: %w", err)
+	}
+	if err := os.Rename(s.path, s.path+".1"); err != nil {
+		return fmt.Errorf("failed to rotate spool file: %w", err)
+	}
+	return s.open()
+}
+
+// Export writes the records to the spool file and syncs it. The context is ignored, as the spool must not be skipped.
+func (s *spoolExporter) Export(_ context.Context, records []sdklog.Record) error {
+	var buf []byte
+	for i := range records {
+		line, err := json.Marshal(toWALRecord(&records[i]))
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("spool file is closed")
+	}
+	if s.file == nil {
+		// A failed rotation is retried by the next export.
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	if s.size > 0 && s.size+int64(len(buf)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(buf)
+	s.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return s.file.Sync()
+}
+
+func (s *spoolExporter) Shutdown(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *spoolExporter) ForceFlush(context.Context) error {
+	return nil
+}
+
+// dialProbe returns a probe that connects to the host and port of endpoint, which is a URL or host:port.
+func dialProbe(endpoint string) func(context.Context) error {
+	address := endpoint
+	if u, err := url.Parse(endpoint); err == nil && u.Host != "" {
+		address = u.Host
+		if u.Port() == "" {
+			port := "80"
+			if u.Scheme == "https" {
+				port = "443"
+			}
+			address = net.JoinHostPort(u.Hostname(), port)
+		}
+	}
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+const defaultSpoolFile = "spool.jsonl"
+
+// getSpoolFile retrieves the path of the spool file from the environment variable.
+// If the variable (AUDIT_SPOOL_FILE) is not set, it returns the default path.
+func getSpoolFile() string {
+	if val, ok := os.LookupEnv("AUDIT_SPOOL_FILE"); ok && val != "" {
+		return val
+	}
+	return defaultSpoolFile
+}
+
+const defaultSpoolMaxSize = 64 << 20
+
+// getSpoolMaxSize retrieves the size in bytes after which the spool file is rotated from the environment variable.
+// If the variable (AUDIT_SPOOL_MAX_SIZE) is not set or invalid, it returns the default size.
+func getSpoolMaxSize() int64 {
+	if val, ok := os.LookupEnv("AUDIT_SPOOL_MAX_SIZE"); ok {
+		if n, err := strconv.ParseInt(val, 10, 64); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultSpoolMaxSize
+}
+
+const defaultFailoverRetryInterval = 10 * time.Second
+
+// getFailoverRetryInterval retrieves the time after which a failed backend is tried again from the environment variable.
+// If the variable (FAILOVER_RETRY_INTERVAL) is not set or invalid, it returns the default interval.
+func getFailoverRetryInterval() time.Duration {
+	if val, ok := os.LookupEnv("FAILOVER_RETRY_INTERVAL"); ok {
+		if d, err := time.ParseDuration(val); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultFailoverRetryInterval
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	olog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+func TestFailoverExporter(t *testing.T) {
+	errExport := errors.New("collector unavailable")
+	replayCtx := context.WithValue(context.Background(), walReplayKey{}, &walReplayResult{})
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		errs     [3]error // of the gRPC, HTTP and spool backends
+		want     [3]int   // records exported by the backends
+		wantErr  error
+		spooling bool
+	}{
+		{name: "gRPC", errs: [3]error{}, want: [3]int{1, 0, 0}},
+		{name: "HTTP if gRPC fails", errs: [3]error{errExport}, want: [3]int{1, 1, 0}},
+		{name: "spool if no collector can be reached", errs: [3]error{errExport, errExport}, want: [3]int{1, 1, 1}, wantErr: errSpooled, spooling: true},
+		{name: "all fail", errs: [3]error{errExport, errExport, errExport}, want: [3]int{1, 1, 1}, wantErr: errExport},
+		{name: "replay skips the spool", ctx: replayCtx, errs: [3]error{errExport, errExport}, want: [3]int{1, 1, 0}, wantErr: errExport},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var exporters [3]*fakeExporter
+			for i := range exporters {
+				exporters[i] = &fakeExporter{err: tt.errs[i]}
+			}
+			f := newFailoverExporter(time.Minute,
+				newBackend("otlpgrpc", exporters[0]),
+				newBackend("otlphttp", exporters[1]),
+				newLocalBackend("spool", exporters[2]),
+			)
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			err := f.Export(ctx, newRecords(t, "a"))
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Export() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == errExport && errors.Is(err, errSpooled) {
+				t.Errorf("Export() error = %v, want no %v", err, errSpooled)
+			}
+			for i, e := range exporters {
+				if got := e.exported(); got != tt.want[i] {
+					t.Errorf("backend %d exported %d records, want %d", i, got, tt.want[i])
+				}
+			}
+			if _, spooling := f.delivery(); spooling != tt.spooling {
+				t.Errorf("spooling = %t, want %t", spooling, tt.spooling)
+			}
+		})
+	}
+}
+
+func TestFailoverExporterSpooledIsQueued(t *testing.T) {
+	errExport := errors.New("collector unavailable")
+	f := newFailoverExporter(time.Minute,
+		newBackend("otlpgrpc", &fakeExporter{err: errExport}),
+		newLocalBackend("spool", &fakeExporter{}),
+	)
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(newDeliveryProcessor(f)))
+	l := newAuditLogger(provider.Logger("DICE_GO_SERVICE"), time.Second, false)
+
+	status, err := l.Emit(context.Background(), olog.Record{})
+	if status != statusQueued || err != nil {
+		t.Errorf("Emit() = %q, %v, want %q", status, err, statusQueued)
+	}
+}
+
+func TestFailoverExporterBackoff(t *testing.T) {
+	const retryInterval = 50 * time.Millisecond
+	grpc, http := &fakeExporter{err: errors.New("collector unavailable")}, &fakeExporter{}
+	f := newFailoverExporter(retryInterval, newBackend("otlpgrpc", grpc), newBackend("otlphttp", http))
+
+	export := func() {
+		t.Helper()
+		if err := f.Export(context.Background(), newRecords(t, "a")); err != nil {
+			t.Fatalf("Export() error = %v", err)
+		}
+	}
+
+	// A failed backend is skipped until its retry interval has elapsed.
+	export()
+	export()
+	if grpc.exported() != 1 || http.exported() != 2 {
+		t.Fatalf("gRPC exported %d and HTTP %d records, want 1 and 2", grpc.exported(), http.exported())
+	}
+	if status := f.status()[0]; status.Healthy || status.Failures != 1 {
+		t.Errorf("gRPC status = %+v, want unhealthy with 1 failure", status)
+	}
+
+	// Once it has elapsed, the backend is tried again and used as soon as it has recovered.
+	time.Sleep(retryInterval)
+	grpc.setErr(nil)
+	export()
+	export()
+	if grpc.exported() != 3 || http.exported() != 2 {
+		t.Errorf("gRPC exported %d and HTTP %d records, want 3 and 2", grpc.exported(), http.exported())
+	}
+	if status := f.status()[0]; !status.Healthy || status.Successes != 2 {
+		t.Errorf("gRPC status = %+v, want healthy with 2 successes", status)
+	}
+}
+
+func TestSpoolExporterRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spool.jsonl")
+	records := newRecords(t, "a")
+	line, err := os.ReadFile(func() string {
+		// The size of a spooled record.
+		probe := filepath.Join(t.TempDir(), "probe.jsonl")
+		s, err := newSpoolExporter(probe, 1<<20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Shutdown(context.Background())
+		if err := s.Export(context.Background(), records); err != nil {
+			t.Fatal(err)
+		}
+		return probe
+	}())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The file holds two records, so the third one starts a new file.
+	s, err := newSpoolExporter(path, int64(2*len(line)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if err := s.Export(context.Background(), records); err != nil {
+			t.Fatalf("Export() error = %v", err)
+		}
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Export(context.Background(), records); err == nil {
+		t.Error("Export() after Shutdown() succeeded")
+	}
+
+	for name, want := range map[string]int{path: 1, path + ".1": 2} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := bytes.Count(data, []byte("\n")); got != want {
+			t.Errorf("%s has %d records, want %d", filepath.Base(name), got, want)
+		}
+	}
+
+	// A reopened spool file keeps its size, so it is not exceeded after a restart.
+	s, err = newSpoolExporter(path, int64(2*len(line)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Shutdown(context.Background())
+	for range 2 {
+		if err := s.Export(context.Background(), records); err != nil {
+			t.Fatalf("Export() error = %v", err)
+		}
+	}
+	if data, _ := os.ReadFile(path); bytes.Count(data, []byte("\n")) != 1 {
+		t.Errorf("spool.jsonl has %d records after the restart, want 1", bytes.Count(data, []byte("\n")))
+	}
+}
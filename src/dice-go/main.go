@@ -11,12 +11,15 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"strconv"
 	"sync"
+	"syscall"
 	"time"
 
 	"go.opentelemetry.io/contrib/bridges/otelslog"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/contrib/otelconf"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
@@ -24,7 +27,9 @@ import (
 	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
 	olog "go.opentelemetry.io/otel/log"
 	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/propagation"
 	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	sdkresource "go.opentelemetry.io/otel/sdk/resource"
 )
 
@@ -32,10 +37,14 @@ var (
 	resource          *sdkresource.Resource
 	initResourcesOnce sync.Once
 
-	cancelContextWith context.CancelCauseFunc
+	// cancelContextWith is set by initResource. Until then it does nothing, so that errors of the setup before,
+	// e.g. of the configuration file, are returned.
+	cancelContextWith context.CancelCauseFunc = func(error) {}
 
-	logger  = global.GetLoggerProvider().Logger("unused")
-	slogger = otelslog.NewLogger("AUDIT-otelslog")
+	logger   = global.GetLoggerProvider().Logger("unused")
+	slogger  = otelslog.NewLogger("AUDIT-otelslog")
+	auditLog *auditLogger
+	bulkLogs *bulkPool
 )
 
 func main() {
@@ -46,8 +55,8 @@ func main() {
 }
 
 func run() (err error) {
-	// Handle SIGINT (CTRL+C) gracefully.
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	// Handle SIGINT (CTRL+C) and SIGTERM (sent by Kubernetes to stop a pod) gracefully.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
 	shutdown, err := setupOTelSDK(ctx)
@@ -62,13 +71,32 @@ func run() (err error) {
 		}
 	}()
 
+	// Start the bulk log workers. They are drained after the HTTP server and before the OpenTelemetry SDK is shut down.
+	bulkLogs, err = newBulkPool(global.GetLoggerProvider().Logger("dice-go/bulk"), getBulkWorkers(), getBulkQueueSize())
+	if err != nil {
+		return fmt.Errorf("failed to start bulk log workers: %w", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), getBulkDrainTimeout())
+		defer cancel()
+		if err := bulkLogs.Drain(ctx); err != nil {
+			fmt.Printf("failed to drain bulk log records: %v\n", err)
+		}
+	}()
+
+	// Set up the authentication of the requests. Without a JWKS file and API keys, all requests are anonymous.
+	auth, err := newAuthenticator(getJWKSFile(), getJWTIssuer(), getJWTAudience(), getAPIKeysFile())
+	if err != nil {
+		return fmt.Errorf("failed to setup authentication: %w", err)
+	}
+
 	// Start HTTP server.
 	srv := &http.Server{
 		Addr:         "0.0.0.0:8081",
 		BaseContext:  func(_ net.Listener) context.Context { return ctx },
 		ReadTimeout:  time.Second,
 		WriteTimeout: 10 * time.Second,
-		Handler:      newHTTPHandler(),
+		Handler:      newHTTPHandler(auth),
 	}
 	srvErr := make(chan error, 1)
 	go func() {
@@ -102,19 +130,35 @@ func rolldice(w http.ResponseWriter, r *http.Request) {
 	} else {
 		msg = "Anonymous player is rolling the dice"
 	}
+	// The request itself is audited by auditMiddleware.
 	slogger.InfoContext(r.Context(), msg, "result", roll, "AUDIT-USER", player)
 
-	// Emit some log records (see getFactor()) using the global logger.
-	factor := getFactor()
-	go func() {
-		for i := 0; i < factor; i++ {
-			rec := olog.Record{}
-			rec.SetSeverity(olog.SeverityInfo)
-			rec.SetBody(olog.StringValue(fmt.Sprintf("dice: %d, user: %s - bulk log message #%d", roll, player, 1+i)))
-			logger.Emit(context.Background(), rec)
-			time.Sleep(10 * time.Millisecond)
-		}
-	}()
+	// No audit, no action: the roll is only returned if its audit record has been delivered.
+	// The headers let the caller correlate the request with its audit record in the sink.
+	recordID := newAuditRecordID()
+	rec := olog.Record{}
+	rec.SetEventName("dice.roll")
+	rec.SetSeverity(olog.SeverityInfo)
+	rec.SetBody(olog.StringValue(msg))
+	rec.AddAttributes(
+		olog.String(auditRecordIDKey, recordID),
+		olog.Int("dice.result", roll),
+	)
+	rec.AddAttributes(actorAttributes(r)...)
+	status, err := auditLog.Emit(r.Context(), rec)
+	w.Header().Set("Audit-Record-Id", recordID)
+	w.Header().Set("Audit-Status", string(status))
+	if err != nil {
+		log.Printf("Audit record could not be delivered: %v\n", err)
+		http.Error(w, "audit record could not be delivered", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Emit some log records (see getFactor()) using an ordinary logger, asynchronously by the bulk log workers.
+	// If their queue is full, the records are dropped and counted, so that the response doesn't wait for them.
+	if err := bulkLogs.Submit(r.Context(), roll, player, getFactor()); err != nil && !errors.Is(err, errBulkQueueFull) {
+		log.Printf("Bulk log records could not be submitted: %v\n", err)
+	}
 
 	resp := strconv.Itoa(roll) + "\n"
 	if _, err := io.WriteString(w, resp); err != nil {
@@ -122,14 +166,15 @@ func rolldice(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func newHTTPHandler() http.Handler {
+func newHTTPHandler(auth *authenticator) http.Handler {
 	mux := http.NewServeMux()
 
 	// handleFunc is a replacement for mux.HandleFunc
 	// which enriches the handler's HTTP instrumentation with the pattern as the http.route.
+	// Unauthenticated requests are rejected by the route, so that their audit events record it.
 	handleFunc := func(pattern string, handlerFunc func(http.ResponseWriter, *http.Request)) {
 		// Configure the "http.route" for the HTTP instrumentation.
-		handler := otelhttp.NewHandler(http.HandlerFunc(handlerFunc), pattern)
+		handler := otelhttp.NewHandler(requireAuth(http.HandlerFunc(handlerFunc)), pattern)
 		mux.Handle(pattern, handler)
 	}
 
@@ -138,7 +183,16 @@ func newHTTPHandler() http.Handler {
 	handleFunc("/rolldice/{player}", rolldice)
 
 	// Add HTTP instrumentation for the whole server.
-	handler := otelhttp.NewHandler(mux, "/")
+	// The audit middleware runs inside of it, so that the audit events are correlated with the request.
+	// The requests are authenticated before, so that the audit events record the verified identity,
+	// but only rejected by the routes of the mux, so that failed authentications are audited with their route and claimed player, too.
+	handler := http.NewServeMux()
+	handler.Handle("/", otelhttp.NewHandler(authMiddleware(auth, auditMiddleware(mux)), "/"))
+
+	// The probes and the status are neither instrumented nor audited, as they are polled.
+	handler.HandleFunc("GET /healthz", healthz)
+	handler.HandleFunc("GET /readyz", readyz)
+	handler.HandleFunc("GET /debug/otel", debugOTel)
 	return handler
 }
 
@@ -160,12 +214,13 @@ func getFactor() int {
 func setupOTelSDK(ctx context.Context) (shutdown func(context.Context) error, err error) {
 	var shutdownFuncs []func(context.Context) error
 
-	// shutdown calls cleanup functions registered via shutdownFuncs.
+	// shutdown calls cleanup functions registered via shutdownFuncs in reverse order,
+	// so that the meter provider is shut down after the logger provider it instruments.
 	// The errors from the calls are joined.
 	// Each registered cleanup will be invoked once.
 	shutdown = func(ctx context.Context) error {
 		var err error
-		for _, fn := range shutdownFuncs {
+		for _, fn := range slices.Backward(shutdownFuncs) {
 			err = errors.Join(err, fn(ctx))
 		}
 		shutdownFuncs = nil
@@ -177,22 +232,59 @@ func setupOTelSDK(ctx context.Context) (shutdown func(context.Context) error, er
 		err = errors.Join(inErr, shutdown(ctx))
 	}
 
-	// Set up logger provider.
-	loggerProvider, err := newLoggerProvider(ctx)
-	if err != nil {
-		handleErr(err)
-		return
+	// Set up the propagator, so that the trace context of incoming requests is used.
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	// Set up logger provider, from the declarative configuration file if there is one.
+	var loggerProvider olog.LoggerProvider
+	configFile := getConfigFile()
+	if configFile != "" {
+		if err = checkConfigFileGuarantees(configFile); err != nil {
+			handleErr(err)
+			return
+		}
+		var sdk otelconf.SDK
+		sdk, err = newConfiguredSDK(ctx)
+		if err != nil {
+			handleErr(err)
+			return
+		}
+		shutdownFuncs = append(shutdownFuncs, sdk.Shutdown)
+		otel.SetMeterProvider(sdk.MeterProvider())
+		loggerProvider = sdk.LoggerProvider()
+		log.Printf("Logger provider configured by %s\n", configFile)
+	} else {
+		// Set up meter provider for the metrics of the log pipeline.
+		var meterProvider *sdkmetric.MeterProvider
+		meterProvider, err = newMeterProvider(ctx)
+		if err != nil {
+			handleErr(err)
+			return
+		}
+		shutdownFuncs = append(shutdownFuncs, meterProvider.Shutdown)
+		otel.SetMeterProvider(meterProvider)
+
+		// The audit and the operational logs have separate pipelines, the loggers are routed by their name and attributes.
+		var auditProvider, operationalProvider *sdklog.LoggerProvider
+		auditProvider, err = newAuditLoggerProvider(ctx)
+		if err != nil {
+			handleErr(err)
+			return
+		}
+		shutdownFuncs = append(shutdownFuncs, auditProvider.Shutdown)
+		operationalProvider, err = newOperationalLoggerProvider(ctx)
+		if err != nil {
+			handleErr(err)
+			return
+		}
+		shutdownFuncs = append(shutdownFuncs, operationalProvider.Shutdown)
+		loggerProvider = newRoutingLoggerProvider(auditProvider, operationalProvider)
 	}
-	shutdownFuncs = append(shutdownFuncs, loggerProvider.Shutdown)
 	global.SetLoggerProvider(loggerProvider)
 
-	otel.GetErrorHandler().Handle(errors.New("handle test error 1"))
 	// Set up the OpenTelemetry error handler.
-
 	otel.SetErrorHandler(&customErrorHandler{})
 
-	otel.GetErrorHandler().Handle(errors.New("handle test error 2"))
-
 	// otel-collector        ClusterIP   10.43.238.160   <none>        6831/UDP,14250/TCP,14268/TCP,8888/TCP,4317/TCP,4318/TCP,9411/TCP   25h
 	// kubectl get services --namespace otel-demo
 	// kubectl describe service otel-collector --namespace otel-demo
@@ -208,19 +300,16 @@ func setupOTelSDK(ctx context.Context) (shutdown func(context.Context) error, er
 
 	logger = loggerProvider.Logger("DICE_GO_SERVICE", // We can set a custom logger name for AUDIT purposes
 		olog.WithInstrumentationAttributes(attribute.String("AUDIT", "DICE_GO_SERVICE"))) // We can set a custom attributes for AUDIT purposes
-
-	rec := olog.Record{}
-	rec.SetSeverity(olog.SeverityInfo)
-	rec.SetBody(olog.StringValue("test log message: dice-go service started"))
-	rec.AddAttributes(olog.KeyValueFromAttribute(attribute.String("AUDIT-key-2", "KeyValueFromAttribute")))
-	logger.Emit(ctx, rec)
+	// The pipeline of a configuration file has no deliveryProcessor, so its audit records can't be confirmed.
+	auditLog = newAuditLogger(logger, getAuditTimeout(), getAuditAsync() || configFile != "")
 
 	return shutdown, err
 }
 
-// newLoggerProvider creates a new logger provider with OTLP exporters and stdout exporter.
+// newAuditLoggerProvider creates a new logger provider for the audit records with a stdout exporter and a failover between
+// the OTLP exporters and a spool file. The records are exported synchronously, written to a write-ahead log and retried forever.
 // It requires the OTEL_EXPORTER_OTLP_ENDPOINT_GRPC and OTEL_EXPORTER_OTLP_ENDPOINT_HTTP environment variables to be set.
-func newLoggerProvider(ctx context.Context) (*sdklog.LoggerProvider, error) {
+func newAuditLoggerProvider(ctx context.Context) (*sdklog.LoggerProvider, error) {
 	grpcEndpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT_GRPC")
 	if grpcEndpoint == "" {
 		grpcEndpoint = "localhost:4317" // Default gRPC endpoint
@@ -247,10 +336,91 @@ func newLoggerProvider(ctx context.Context) (*sdklog.LoggerProvider, error) {
 		return nil, err
 	}
 
+	spoolExporter, err := newSpoolExporter(getSpoolFile(), getSpoolMaxSize())
+	if err != nil {
+		return nil, err
+	}
+
+	// Every exporter records its export attempts, failures and latency as metrics.
+	metrics, err := newExportMetrics()
+	if err != nil {
+		return nil, err
+	}
+
+	// Every record is exported once: with gRPC, with HTTP if gRPC fails, and to the spool file if no collector can be reached.
+	failover := newFailoverExporter(getFailoverRetryInterval(),
+		newCollectorBackend("otlpgrpc", metrics.instrument(grpcExporter, "otlpgrpc"), grpcEndpoint),
+		newCollectorBackend("otlphttp", metrics.instrument(httpExporter, "otlphttp"), httpEndpoint),
+		newLocalBackend("spool", metrics.instrument(spoolExporter, "spool")),
+	)
+
+	// The records that could not be exported by any backend are written to a dead-letter file and counted.
+	failedRecords, err := newFailedRecordsCounter()
+	if err != nil {
+		return nil, err
+	}
+	exportErrorHandler := exportErrorHandlers{newDeadLetterFile(getDeadLetterFile()), failedRecords}
+
+	// The records are written to a write-ahead log, so that they survive collector outages and restarts.
+	res := initResource()
+	wal, err := newWALExporter(newErrorHandlingExporter(failover, "failover", exportErrorHandler), getWALDir(), res)
+	if err != nil {
+		return nil, err
+	}
+	pipeline = auditPipeline{failover: failover, wal: wal}
+	go wal.retryEvery(getFailoverRetryInterval())
+
+	// Every record is stamped with the producer epoch and a sequence number, so that lost records can be detected.
+	sequence, err := newSequenceProcessor(getEpochFile())
+	if err != nil {
+		return nil, err
+	}
+	opts := []sdklog.LoggerProviderOption{sdklog.WithProcessor(sequence)}
+	// The audit records are signed before they are exported, if there is a signing key.
+	if keyFile := getSigningKeyFile(); keyFile != "" {
+		signer, err := newSigner(keyFile, getSigningKeyID())
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdklog.WithProcessor(newSigningProcessor(signer)))
+	}
+	opts = append(opts,
+		sdklog.WithProcessor(sdklog.NewSimpleProcessor(metrics.instrument(stdoutExporter, "stdout"))),
+		// The export result is reported to the auditLogger, so it knows whether a record has been delivered.
+		sdklog.WithProcessor(newDeliveryProcessor(wal)),
+		sdklog.WithResource(res),
+	)
+	loggerProvider := sdklog.NewLoggerProvider(opts...)
+	return loggerProvider, nil
+}
+
+// newOperationalLoggerProvider creates a new logger provider for the operational logs, which are batched, sampled and
+// exported with OTLP/gRPC only. Records that can't be exported are lost.
+// It requires the OTEL_EXPORTER_OTLP_ENDPOINT_GRPC environment variable to be set.
+func newOperationalLoggerProvider(ctx context.Context) (*sdklog.LoggerProvider, error) {
+	grpcEndpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT_GRPC")
+	if grpcEndpoint == "" {
+		grpcEndpoint = "localhost:4317" // Default gRPC endpoint
+	}
+	grpcExporter, err := otlploggrpc.New(ctx, otlploggrpc.WithEndpointURL(grpcEndpoint+"/v1/logs"))
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := newExportMetrics()
+	if err != nil {
+		return nil, err
+	}
+
+	// The records are rate-limited and sampled before they are queued.
+	sampling, err := newSamplingProcessor(getLogRateLimit(), getLogSampleRatio(),
+		sdklog.NewBatchProcessor(metrics.instrument(grpcExporter, "operational")))
+	if err != nil {
+		return nil, err
+	}
+
 	loggerProvider := sdklog.NewLoggerProvider(
-		sdklog.WithProcessor(sdklog.NewSimpleProcessor(stdoutExporter)),
-		sdklog.WithProcessor(sdklog.NewSimpleProcessor(httpExporter)),
-		sdklog.WithProcessor(sdklog.NewSimpleProcessor(grpcExporter)),
+		sdklog.WithProcessor(sampling),
 		sdklog.WithResource(initResource()),
 	)
 	return loggerProvider, nil
@@ -284,5 +454,6 @@ type customErrorHandler struct{}
 
 func (h *customErrorHandler) Handle(err error) {
 	// Custom error handling doesn't really help to guarantee any log delivery, because we don't know what happened to the log record.
+	// The records of failed exports are passed to the ExportErrorHandler instead.
 	fmt.Printf("My-OpenTelemetry error: %v\n", err)
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/log/noop"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+func TestRolldiceAuditStatus(t *testing.T) {
+	errExport := errors.New("collector unavailable")
+	var err error
+	bulkLogs, err = newBulkPool(noop.NewLoggerProvider().Logger("dice-go/bulk"), 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = bulkLogs.Drain(context.Background()) })
+
+	tests := []struct {
+		name       string
+		err        error
+		wantCode   int
+		wantStatus deliveryStatus
+	}{
+		{name: "delivered", wantCode: http.StatusOK, wantStatus: statusDelivered},
+		{name: "queued", err: errors.Join(errSpooled, errExport), wantCode: http.StatusOK, wantStatus: statusQueued},
+		{name: "failed", err: errExport, wantCode: http.StatusServiceUnavailable, wantStatus: statusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := &fakeExporter{err: tt.err}
+			provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(newDeliveryProcessor(exporter)))
+			auditLog = newAuditLogger(provider.Logger("DICE_GO_SERVICE"), time.Second, false)
+
+			r := httptest.NewRequest("GET", "/rolldice/bob", nil)
+			r.SetPathValue("player", "bob")
+			w := httptest.NewRecorder()
+			rolldice(w, r)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d", w.Code, tt.wantCode)
+			}
+			if got := w.Header().Get("Audit-Status"); got != string(tt.wantStatus) {
+				t.Errorf("Audit-Status = %q, want %q", got, tt.wantStatus)
+			}
+			recordID := w.Header().Get("Audit-Record-Id")
+			if exporter.exported() != 1 {
+				t.Fatalf("exported %d records, want 1", exporter.exported())
+			}
+			if got, _ := attributeValue(&exporter.records[0], auditRecordIDKey); recordID == "" || got != recordID {
+				t.Errorf("Audit-Record-Id = %q, want the %s %q of the record", recordID, auditRecordIDKey, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/metric"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// newMeterProvider creates a new meter provider that exports the metrics of the log pipeline to the collector.
+// It uses the OTEL_EXPORTER_OTLP_ENDPOINT_GRPC environment variable like newAuditLoggerProvider. The export interval can be
+// set with OTEL_METRIC_EXPORT_INTERVAL (in milliseconds, default 60000).
+func newMeterProvider(ctx context.Context) (*sdkmetric.MeterProvider, error) {
+	grpcEndpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT_GRPC")
+	if grpcEndpoint == "" {
+		grpcEndpoint = "localhost:4317" // Default gRPC endpoint
+	}
+	exporter, err := otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithEndpointURL(grpcEndpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	meterProvider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter)),
+		sdkmetric.WithResource(initResource()),
+	)
+	return meterProvider, nil
+}
+
+// exportMetrics are the instruments of the log exporters. Records that could not be exported by any backend are
+// counted as dropped by the failedRecordsCounter. Like the failedRecordsCounter, they use the global MeterProvider.
+type exportMetrics struct {
+	attempts metric.Int64Counter
+	failures metric.Int64Counter
+	records  metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+func newExportMetrics() (*exportMetrics, error) {
+	meter := otel.Meter("dice-go")
+	m := &exportMetrics{}
+	var err error
+	if m.attempts, err = meter.Int64Counter("dice_go.log.export.attempts",
+		metric.WithDescription("Number of export operations."),
+		metric.WithUnit("{export}"),
+	); err != nil {
+		return nil, err
+	}
+	if m.failures, err = meter.Int64Counter("dice_go.log.export.failures",
+		metric.WithDescription("Number of failed export operations."),
+		metric.WithUnit("{export}"),
+	); err != nil {
+		return nil, err
+	}
+	if m.records, err = meter.Int64Counter("dice_go.log.export.records",
+		metric.WithDescription("Number of log records that have been exported."),
+		metric.WithUnit("{record}"),
+	); err != nil {
+		return nil, err
+	}
+	if m.duration, err = meter.Float64Histogram("dice_go.log.export.duration",
+		metric.WithDescription("Duration of export operations."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10),
+	); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// instrument returns exporter, recording its export attempts, failures, exported records and export latency.
+func (m *exportMetrics) instrument(exporter sdklog.Exporter, name string) sdklog.Exporter {
+	return &instrumentedExporter{
+		Exporter: exporter,
+		metrics:  m,
+		attrs:    metric.WithAttributeSet(attribute.NewSet(attribute.String("exporter", name))),
+	}
+}
+
+// instrumentedExporter records the metrics of an exporter.
+type instrumentedExporter struct {
+	sdklog.Exporter
+	metrics *exportMetrics
+	attrs   metric.MeasurementOption
+}
+
+func (e *instrumentedExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	start := time.Now()
+	err := e.Exporter.Export(ctx, records)
+
+	// The context of the export may already be canceled, which doesn't matter for the metrics.
+	ctx = context.WithoutCancel(ctx)
+	e.metrics.attempts.Add(ctx, 1, e.attrs)
+	e.metrics.duration.Record(ctx, time.Since(start).Seconds(), e.attrs)
+	if err != nil {
+		e.metrics.failures.Add(ctx, 1, e.attrs)
+	} else {
+		e.metrics.records.Add(ctx, int64(len(records)), e.attrs)
+	}
+	return err
+}
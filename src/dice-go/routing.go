@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	olog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/embedded"
+)
+
+// auditScopeAttribute is the instrumentation scope attribute, and auditScopePrefix the prefix of the logger name,
+// that mark the records of a logger as audit records.
+const (
+	auditScopeAttribute = "AUDIT"
+	auditScopePrefix    = "AUDIT"
+)
+
+// isAudit reports whether a logger with the scope attributes and name is an audit logger.
+func isAudit(scope attribute.Set, name string) bool {
+	return scope.HasValue(auditScopeAttribute) || strings.HasPrefix(name, auditScopePrefix)
+}
+
+// routingLoggerProvider returns the loggers of the audit pipeline for audit loggers (see isAudit) and the loggers of the
+// operational pipeline for all others. It is registered as the global LoggerProvider, so that bridges like otelslog are
+// routed by the name and attributes of their logger.
+type routingLoggerProvider struct {
+	embedded.LoggerProvider
+
+	audit       olog.LoggerProvider
+	operational olog.LoggerProvider
+}
+
+var _ olog.LoggerProvider = (*routingLoggerProvider)(nil)
+
+func newRoutingLoggerProvider(audit, operational olog.LoggerProvider) *routingLoggerProvider {
+	return &routingLoggerProvider{audit: audit, operational: operational}
+}
+
+func (p *routingLoggerProvider) Logger(name string, options ...olog.LoggerOption) olog.Logger {
+	cfg := olog.NewLoggerConfig(options...)
+	if isAudit(cfg.InstrumentationAttributes(), name) {
+		return p.audit.Logger(name, options...)
+	}
+	return p.operational.Logger(name, options...)
+}
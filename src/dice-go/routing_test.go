@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/otel/attribute"
+	olog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+func TestRoutingLoggerProvider(t *testing.T) {
+	tests := []struct {
+		name      string
+		logger    string
+		attrs     []attribute.KeyValue
+		wantAudit bool
+	}{
+		{name: "audit attribute", logger: "DICE_GO_SERVICE", attrs: []attribute.KeyValue{attribute.String(auditScopeAttribute, "DICE_GO_SERVICE")}, wantAudit: true},
+		{name: "empty audit attribute", logger: "dice-go", attrs: []attribute.KeyValue{attribute.String(auditScopeAttribute, "")}, wantAudit: true},
+		{name: "name prefix", logger: "AUDIT-otelslog", wantAudit: true},
+		{name: "name prefix and other attributes", logger: "AUDIT", attrs: []attribute.KeyValue{attribute.String("team", "dice")}, wantAudit: true},
+		{name: "ordinary logger", logger: "dice-go/bulk"},
+		{name: "lowercase name prefix", logger: "audit-otelslog"},
+		{name: "prefix not at the start", logger: "dice-go/AUDIT"},
+		{name: "lowercase attribute", logger: "dice-go", attrs: []attribute.KeyValue{attribute.String("audit", "dice-go")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit, operational := &recordingProcessor{}, &recordingProcessor{}
+			p := newRoutingLoggerProvider(
+				sdklog.NewLoggerProvider(sdklog.WithProcessor(audit)),
+				sdklog.NewLoggerProvider(sdklog.WithProcessor(operational)),
+			)
+			p.Logger(tt.logger, olog.WithInstrumentationAttributes(tt.attrs...)).Emit(context.Background(), olog.Record{})
+
+			gotAudit, gotOperational := len(audit.records), len(operational.records)
+			if tt.wantAudit && (gotAudit != 1 || gotOperational != 0) || !tt.wantAudit && (gotAudit != 0 || gotOperational != 1) {
+				t.Errorf("got %d audit and %d operational records, want audit = %t", gotAudit, gotOperational, tt.wantAudit)
+			}
+		})
+	}
+
+	t.Run("otelslog bridge", func(t *testing.T) {
+		// The bridge gets its logger from the global LoggerProvider, so it is routed by its name.
+		audit, operational := &recordingProcessor{}, &recordingProcessor{}
+		global.SetLoggerProvider(newRoutingLoggerProvider(
+			sdklog.NewLoggerProvider(sdklog.WithProcessor(audit)),
+			sdklog.NewLoggerProvider(sdklog.WithProcessor(operational)),
+		))
+		otelslog.NewLogger("AUDIT-otelslog").Info("bob is rolling the dice")
+		otelslog.NewLogger("dice-go/debug").Info("debug")
+
+		if len(audit.records) != 1 || audit.records[0].InstrumentationScope().Name != "AUDIT-otelslog" {
+			t.Errorf("audit pipeline got %d records, want the one of AUDIT-otelslog", len(audit.records))
+		}
+		if len(operational.records) != 1 || operational.records[0].InstrumentationScope().Name != "dice-go/debug" {
+			t.Errorf("operational pipeline got %d records, want the one of dice-go/debug", len(operational.records))
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// samplingProcessor rate-limits and samples the ordinary log records before passing them on to its processors,
+// so that noisy logging can't flood the pipeline. Records of audit loggers (see isAudit) are never dropped.
+type samplingProcessor struct {
+	processors []sdklog.Processor
+	limiter    *tokenBucket
+	ratio      float64
+	dropped    metric.Int64Counter
+}
+
+var _ sdklog.Processor = (*samplingProcessor)(nil)
+
+// newSamplingProcessor keeps at most limit ordinary records per second, or all of them if limit is 0,
+// and a random ratio (between 0 and 1) of those.
+func newSamplingProcessor(limit, ratio float64, processors ...sdklog.Processor) (*samplingProcessor, error) {
+	dropped, err := newDroppedRecordsCounter()
+	if err != nil {
+		return nil, err
+	}
+	p := &samplingProcessor{processors: processors, ratio: ratio, dropped: dropped}
+	if limit > 0 {
+		p.limiter = newTokenBucket(limit)
+	}
+	return p, nil
+}
+
+// newDroppedRecordsCounter returns the counter of the ordinary log records that have been dropped.
+// Its attribute reason is rate_limit, sampling or queue_full (see bulkPool).
+func newDroppedRecordsCounter() (metric.Int64Counter, error) {
+	return otel.Meter("dice-go").Int64Counter("dice_go.log.dropped_records",
+		metric.WithDescription("Number of ordinary log records dropped by rate limiting, sampling or a full bulk log queue."),
+		metric.WithUnit("{record}"),
+	)
+}
+
+func (p *samplingProcessor) Enabled(ctx context.Context, param sdklog.EnabledParameters) bool {
+	for _, processor := range p.processors {
+		if processor.Enabled(ctx, param) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *samplingProcessor) OnEmit(ctx context.Context, rec *sdklog.Record) error {
+	scope := rec.InstrumentationScope()
+	if !isAudit(scope.Attributes, scope.Name) {
+		if p.limiter != nil && !p.limiter.allow() {
+			p.dropped.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "rate_limit")))
+			return nil
+		}
+		if p.ratio < 1 && rand.Float64() >= p.ratio {
+			p.dropped.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", "sampling")))
+			return nil
+		}
+	}
+
+	var err error
+	for _, processor := range p.processors {
+		err = errors.Join(err, processor.OnEmit(ctx, rec))
+	}
+	return err
+}
+
+func (p *samplingProcessor) Shutdown(ctx context.Context) error {
+	var err error
+	for _, processor := range p.processors {
+		err = errors.Join(err, processor.Shutdown(ctx))
+	}
+	return err
+}
+
+func (p *samplingProcessor) ForceFlush(ctx context.Context) error {
+	var err error
+	for _, processor := range p.processors {
+		err = errors.Join(err, processor.ForceFlush(ctx))
+	}
+	return err
+}
+
+// tokenBucket allows rate events per second on average, with bursts of up to rate events (at least 1).
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	return &tokenBucket{rate: rate, tokens: max(rate, 1), last: time.Now()}
+}
+
+func (b *tokenBucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens = min(max(b.rate, 1), b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// getLogRateLimit retrieves the maximum number of ordinary log records per second from the environment variable.
+// If the variable (LOG_RATE_LIMIT) is not set or invalid, it returns 0, i.e. no limit.
+func getLogRateLimit() float64 {
+	if val, ok := os.LookupEnv("LOG_RATE_LIMIT"); ok {
+		if n, err := strconv.ParseFloat(val, 64); err == nil && n > 0 {
+			return n
+		}
+	}
+	return 0
+}
+
+// getLogSampleRatio retrieves the ratio of ordinary log records to keep from the environment variable.
+// If the variable (LOG_SAMPLE_RATIO) is not set or invalid, it returns 1, i.e. all records are kept.
+func getLogSampleRatio() float64 {
+	if val, ok := os.LookupEnv("LOG_SAMPLE_RATIO"); ok {
+		if r, err := strconv.ParseFloat(val, 64); err == nil && r >= 0 && r <= 1 {
+			return r
+		}
+	}
+	return 1
+}
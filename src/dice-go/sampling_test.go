@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	olog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+func TestSamplingProcessor(t *testing.T) {
+	const emitted = 1000
+
+	tests := []struct {
+		name  string
+		limit float64
+		ratio float64
+		// logger is the name of the logger and audit whether it has the AUDIT attribute.
+		logger string
+		audit  bool
+		// The number of kept records is expected to be between min and max.
+		min, max int
+	}{
+		{name: "no limit", ratio: 1, logger: "dice-go/bulk", min: emitted, max: emitted},
+		{name: "burst of the rate limit", limit: 10, ratio: 1, logger: "dice-go/bulk", min: 10, max: 10},
+		{name: "burst of at least 1", limit: 0.5, ratio: 1, logger: "dice-go/bulk", min: 1, max: 1},
+		{name: "sampling", ratio: 0.5, logger: "dice-go/bulk", min: 400, max: 600},
+		{name: "sampling everything out", ratio: 0, logger: "dice-go/bulk", min: 0, max: 0},
+		{name: "audit logger name", limit: 10, ratio: 0, logger: "AUDIT-otelslog", min: emitted, max: emitted},
+		{name: "audit attribute", limit: 10, ratio: 0, logger: "DICE_GO_SERVICE", audit: true, min: emitted, max: emitted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &recordingProcessor{}
+			p, err := newSamplingProcessor(tt.limit, tt.ratio, recorder)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var opts []olog.LoggerOption
+			if tt.audit {
+				opts = append(opts, olog.WithInstrumentationAttributes(attribute.String(auditScopeAttribute, tt.logger)))
+			}
+			logger := sdklog.NewLoggerProvider(sdklog.WithProcessor(p)).Logger(tt.logger, opts...)
+			for range emitted {
+				logger.Emit(context.Background(), olog.Record{})
+			}
+			if got := len(recorder.records); got < tt.min || got > tt.max {
+				t.Errorf("kept %d of %d records, want between %d and %d", got, emitted, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(10)
+
+	// allowN returns how many of n events are allowed.
+	allowN := func(n int) int {
+		allowed := 0
+		for range n {
+			if b.allow() {
+				allowed++
+			}
+		}
+		return allowed
+	}
+
+	if got := allowN(20); got != 10 {
+		t.Errorf("burst allowed %d of 20 events, want 10", got)
+	}
+
+	// Half a second refills half of the bucket.
+	b.mu.Lock()
+	b.last = b.last.Add(-500 * time.Millisecond)
+	b.mu.Unlock()
+	if got := allowN(20); got != 5 {
+		t.Errorf("allowed %d of 20 events after 500ms, want 5", got)
+	}
+
+	// A long pause refills the bucket, but not beyond its burst.
+	b.mu.Lock()
+	b.last = b.last.Add(-time.Hour)
+	b.mu.Unlock()
+	if got := allowN(20); got != 10 {
+		t.Errorf("allowed %d of 20 events after an hour, want 10", got)
+	}
+}
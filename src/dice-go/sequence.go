@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+
+	olog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// Attribute keys of the position of a record in the stream of the service.
+const (
+	producerEpochKey    = "log.producer.epoch"
+	producerSequenceKey = "log.producer.sequence"
+)
+
+// sequenceProcessor stamps every record with the producer epoch, which is increased on each start of the service,
+// and a sequence number, which starts at 1 in every epoch and is increased by 1 for every record. A verifier can
+// detect lost records as gaps in the sequence numbers of an epoch, and lost epochs as gaps in the epochs.
+// It has to be registered before the processors that sign or export the records.
+type sequenceProcessor struct {
+	epoch    int64
+	sequence atomic.Int64
+}
+
+var _ sdklog.Processor = (*sequenceProcessor)(nil)
+
+// newSequenceProcessor starts a new epoch, which is stored in the file at path.
+func newSequenceProcessor(path string) (*sequenceProcessor, error) {
+	epoch, err := nextEpoch(path)
+	if err != nil {
+		return nil, err
+	}
+	return &sequenceProcessor{epoch: epoch}, nil
+}
+
+// nextEpoch reads the last epoch from path, increases it and writes it back before it is used.
+// The file is replaced atomically, so that no epoch is used twice, even if the service crashes.
+func nextEpoch(path string) (int64, error) {
+	var epoch int64
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return 0, fmt.Errorf("failed to read producer epoch: %w", err)
+	default:
+		epoch, err = strconv.ParseInt(string(bytes.TrimSpace(data)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse producer epoch: %w", err)
+		}
+	}
+	epoch++
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return 0, fmt.Errorf("failed to create producer epoch directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write producer epoch: %w", err)
+	}
+	_, err = f.WriteString(strconv.FormatInt(epoch, 10) + "\n")
+	if err == nil {
+		err = f.Sync()
+	}
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write producer epoch: %w", err)
+	}
+	return epoch, nil
+}
+
+// Enabled returns false, as the processor doesn't export the records itself.
+func (*sequenceProcessor) Enabled(context.Context, sdklog.EnabledParameters) bool {
+	return false
+}
+
+func (p *sequenceProcessor) OnEmit(_ context.Context, rec *sdklog.Record) error {
+	rec.AddAttributes(
+		olog.Int64(producerEpochKey, p.epoch),
+		olog.Int64(producerSequenceKey, p.sequence.Add(1)),
+	)
+	return nil
+}
+
+func (*sequenceProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (*sequenceProcessor) ForceFlush(context.Context) error {
+	return nil
+}
+
+const defaultEpochFile = "epoch"
+
+// getEpochFile retrieves the path of the file storing the producer epoch from the environment variable.
+// If the variable (PRODUCER_EPOCH_FILE) is not set, it returns the default path.
+func getEpochFile() string {
+	if val, ok := os.LookupEnv("PRODUCER_EPOCH_FILE"); ok && val != "" {
+		return val
+	}
+	return defaultEpochFile
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	olog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+func TestSequenceProcessorAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "epoch")
+
+	for wantEpoch := int64(1); wantEpoch <= 3; wantEpoch++ {
+		// Every start of the service reopens the epoch file.
+		p, err := newSequenceProcessor(path)
+		if err != nil {
+			t.Fatalf("newSequenceProcessor() error = %v", err)
+		}
+		recorder := &recordingProcessor{}
+		logger := sdklog.NewLoggerProvider(sdklog.WithProcessor(p), sdklog.WithProcessor(recorder)).Logger("DICE_GO_SERVICE")
+		for range 2 {
+			logger.Emit(context.Background(), olog.Record{})
+		}
+
+		for i, rec := range recorder.records {
+			var epoch, sequence int64
+			rec.WalkAttributes(func(kv olog.KeyValue) bool {
+				switch kv.Key {
+				case producerEpochKey:
+					epoch = kv.Value.AsInt64()
+				case producerSequenceKey:
+					sequence = kv.Value.AsInt64()
+				}
+				return true
+			})
+			if epoch != wantEpoch || sequence != int64(i+1) {
+				t.Errorf("record %d has epoch %d and sequence %d, want %d and %d", i, epoch, sequence, wantEpoch, i+1)
+			}
+		}
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary epoch file was left behind: %v", err)
+	}
+
+	t.Run("invalid epoch file", func(t *testing.T) {
+		if err := os.WriteFile(path, []byte("not a number\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := newSequenceProcessor(path); err == nil {
+			t.Error("newSequenceProcessor() succeeded")
+		}
+	})
+}
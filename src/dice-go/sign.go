@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	olog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// Attribute keys of the signature of an audit record.
+const (
+	signatureKey          = "audit.signature"
+	signatureKeyIDKey     = "audit.signature.key_id"
+	signatureAlgorithmKey = "audit.signature.algorithm"
+)
+
+// signer signs the canonical form of audit records with a local key.
+type signer struct {
+	algorithm string
+	keyID     string
+	sign      func(data []byte) ([]byte, error)
+}
+
+// newSigner loads the key from path. A PEM encoded PKCS #8 Ed25519 private key is used for Ed25519 signatures,
+// any other content is used as the secret of an HMAC-SHA256, without surrounding whitespace.
+// If keyID is empty, the first 8 bytes of the SHA-256 of the public key (Ed25519) or the secret (HMAC) are used.
+func newSigner(path, keyID string) (*signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	s := &signer{keyID: keyID}
+	var fingerprint [sha256.Size]byte
+	if block, _ := pem.Decode(data); block != nil {
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse signing key: %w", err)
+		}
+		privateKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("unsupported signing key type %T", key)
+		}
+		s.algorithm = "ed25519"
+		s.sign = func(data []byte) ([]byte, error) {
+			return privateKey.Sign(nil, data, crypto.Hash(0))
+		}
+		fingerprint = sha256.Sum256(privateKey.Public().(ed25519.PublicKey))
+	} else {
+		secret := bytes.TrimSpace(data)
+		if len(secret) == 0 {
+			return nil, fmt.Errorf("signing key %s is empty", path)
+		}
+		s.algorithm = "hmac-sha256"
+		s.sign = func(data []byte) ([]byte, error) {
+			mac := hmac.New(sha256.New, secret)
+			mac.Write(data)
+			return mac.Sum(nil), nil
+		}
+		fingerprint = sha256.Sum256(secret)
+	}
+	if s.keyID == "" {
+		s.keyID = hex.EncodeToString(fingerprint[:8])
+	}
+	return s, nil
+}
+
+// signedRecord is the canonical form of an audit record that is signed: the body and the attributes sorted by key,
+// with the keys of maps sorted as well, serialized as compact JSON in the format of the write-ahead log.
+// The attributes of the signature itself are not part of it.
+type signedRecord struct {
+	Body       *walValue `json:"body,omitempty"`
+	Attributes []walKV   `json:"attributes"`
+}
+
+// canonicalize returns the canonical form of rec.
+func canonicalize(rec *sdklog.Record) ([]byte, error) {
+	r := signedRecord{Attributes: []walKV{}}
+	if body := rec.Body(); body.Kind() != olog.KindEmpty {
+		v := sortMaps(toWALValue(body))
+		r.Body = &v
+	}
+	rec.WalkAttributes(func(kv olog.KeyValue) bool {
+		switch kv.Key {
+		case signatureKey, signatureKeyIDKey, signatureAlgorithmKey:
+		default:
+			r.Attributes = append(r.Attributes, walKV{Key: kv.Key, Value: sortMaps(toWALValue(kv.Value))})
+		}
+		return true
+	})
+	sortKVs(r.Attributes)
+	return json.Marshal(r)
+}
+
+func sortMaps(v walValue) walValue {
+	if v.Slice != nil {
+		for i := range *v.Slice {
+			(*v.Slice)[i] = sortMaps((*v.Slice)[i])
+		}
+	}
+	if v.Map != nil {
+		for i := range *v.Map {
+			(*v.Map)[i].Value = sortMaps((*v.Map)[i].Value)
+		}
+		sortKVs(*v.Map)
+	}
+	return v
+}
+
+func sortKVs(kvs []walKV) {
+	slices.SortStableFunc(kvs, func(a, b walKV) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+}
+
+// signingProcessor signs the audit records, i.e. the records of audit loggers (see isAudit), and attaches the signature and the key ID
+// as attributes, so that modifications between the service and the sink can be detected.
+// It has to be registered before the processors that export the records.
+type signingProcessor struct {
+	signer *signer
+}
+
+var _ sdklog.Processor = (*signingProcessor)(nil)
+
+func newSigningProcessor(signer *signer) *signingProcessor {
+	return &signingProcessor{signer: signer}
+}
+
+// Enabled returns false, as the processor doesn't export the records itself.
+func (*signingProcessor) Enabled(context.Context, sdklog.EnabledParameters) bool {
+	return false
+}
+
+func (p *signingProcessor) OnEmit(_ context.Context, rec *sdklog.Record) error {
+	if scope := rec.InstrumentationScope(); !isAudit(scope.Attributes, scope.Name) {
+		return nil
+	}
+	data, err := canonicalize(rec)
+	if err != nil {
+		return fmt.Errorf("failed to canonicalize audit record: %w", err)
+	}
+	signature, err := p.signer.sign(data)
+	if err != nil {
+		return fmt.Errorf("failed to sign audit record: %w", err)
+	}
+	rec.AddAttributes(
+		olog.String(signatureKey, base64.StdEncoding.EncodeToString(signature)),
+		olog.String(signatureKeyIDKey, p.signer.keyID),
+		olog.String(signatureAlgorithmKey, p.signer.algorithm),
+	)
+	return nil
+}
+
+func (*signingProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (*signingProcessor) ForceFlush(context.Context) error {
+	return nil
+}
+
+// getSigningKeyFile retrieves the path of the key to sign the audit records with from the environment variable.
+// If the variable (AUDIT_SIGNING_KEY_FILE) is not set, it returns an empty string and the records are not signed.
+func getSigningKeyFile() string {
+	return os.Getenv("AUDIT_SIGNING_KEY_FILE")
+}
+
+// getSigningKeyID retrieves the ID of the signing key from the environment variable (AUDIT_SIGNING_KEY_ID).
+// If it is empty, the ID is derived from the key.
+func getSigningKeyID() string {
+	return os.Getenv("AUDIT_SIGNING_KEY_ID")
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	olog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// recordingProcessor keeps the emitted records.
+type recordingProcessor struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (p *recordingProcessor) Enabled(context.Context, sdklog.EnabledParameters) bool { return true }
+
+func (p *recordingProcessor) OnEmit(_ context.Context, rec *sdklog.Record) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.records = append(p.records, rec.Clone())
+	return nil
+}
+
+func (p *recordingProcessor) Shutdown(context.Context) error   { return nil }
+func (p *recordingProcessor) ForceFlush(context.Context) error { return nil }
+
+// attributeValue returns the string value of the attribute key of rec.
+func attributeValue(rec *sdklog.Record, key string) (string, bool) {
+	var value string
+	var found bool
+	rec.WalkAttributes(func(kv olog.KeyValue) bool {
+		if kv.Key == key {
+			value, found = kv.Value.AsString(), true
+			return false
+		}
+		return true
+	})
+	return value, found
+}
+
+func TestSigningProcessor(t *testing.T) {
+	dir := t.TempDir()
+
+	secret := []byte("hmac secret")
+	hmacKey := filepath.Join(dir, "hmac.key")
+	if err := os.WriteFile(hmacKey, append(secret, '\n'), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed25519Key := filepath.Join(dir, "ed25519.pem")
+	if err := os.WriteFile(ed25519Key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		keyFile   string
+		algorithm string
+		verify    func(data, signature []byte) bool
+	}{
+		{
+			name:      "hmac",
+			keyFile:   hmacKey,
+			algorithm: "hmac-sha256",
+			verify: func(data, signature []byte) bool {
+				mac := hmac.New(sha256.New, secret)
+				mac.Write(data)
+				return hmac.Equal(mac.Sum(nil), signature)
+			},
+		},
+		{
+			name:      "ed25519",
+			keyFile:   ed25519Key,
+			algorithm: "ed25519",
+			verify: func(data, signature []byte) bool {
+				return ed25519.Verify(publicKey, data, signature)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newSigner(tt.keyFile, "key-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorder := &recordingProcessor{}
+			provider := sdklog.NewLoggerProvider(
+				sdklog.WithProcessor(newSigningProcessor(s)),
+				sdklog.WithProcessor(recorder),
+			)
+
+			// The records of the otelslog bridge have no event name, but are audit records by the name of their logger.
+			emit := func(logger olog.Logger) {
+				rec := olog.Record{}
+				rec.SetBody(olog.StringValue("bob is rolling the dice"))
+				rec.AddAttributes(
+					olog.Int("result", 42),
+					olog.Map("details", olog.String("z", "last"), olog.String("a", "first")),
+				)
+				logger.Emit(context.Background(), rec)
+			}
+			emit(provider.Logger("AUDIT-otelslog"))
+			emit(provider.Logger("DICE_GO_SERVICE", olog.WithInstrumentationAttributes(attribute.String(auditScopeAttribute, "DICE_GO_SERVICE"))))
+			emit(provider.Logger("dice-go/bulk"))
+
+			if len(recorder.records) != 3 {
+				t.Fatalf("got %d records, want 3", len(recorder.records))
+			}
+			for _, rec := range recorder.records[:2] {
+				encoded, ok := attributeValue(&rec, signatureKey)
+				if !ok {
+					t.Fatalf("record of logger %q is not signed", rec.InstrumentationScope().Name)
+				}
+				signature, err := base64.StdEncoding.DecodeString(encoded)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, err := canonicalize(&rec)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !tt.verify(data, signature) {
+					t.Errorf("signature of logger %q does not verify over %s", rec.InstrumentationScope().Name, data)
+				}
+				if got, _ := attributeValue(&rec, signatureKeyIDKey); got != "key-1" {
+					t.Errorf("key ID = %q, want %q", got, "key-1")
+				}
+				if got, _ := attributeValue(&rec, signatureAlgorithmKey); got != tt.algorithm {
+					t.Errorf("algorithm = %q, want %q", got, tt.algorithm)
+				}
+
+				// A modified record must not verify.
+				rec.AddAttributes(olog.String("injected", "true"))
+				data, err = canonicalize(&rec)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tt.verify(data, signature) {
+					t.Errorf("signature of logger %q verifies over a modified record", rec.InstrumentationScope().Name)
+				}
+			}
+			if _, ok := attributeValue(&recorder.records[2], signatureKey); ok {
+				t.Error("record of an ordinary logger is signed")
+			}
+		})
+	}
+}
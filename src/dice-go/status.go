@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// auditPipeline holds the parts of the built-in pipeline that report their status.
+// They are nil if the logger provider is configured by a file, see getConfigFile().
+type auditPipeline struct {
+	failover *failoverExporter
+	wal      *walExporter
+}
+
+var pipeline auditPipeline
+
+// pipelineStatus is the response of /debug/otel.
+type pipelineStatus struct {
+	Ready bool `json:"ready"`
+	// FailingFor is how long the audit records have not been exported to a collector, in seconds.
+	FailingFor float64 `json:"failingFor"`
+	// LastDelivered is the time of the last export to a collector.
+	LastDelivered time.Time `json:"lastDelivered,omitzero"`
+	// Spooling is set if the audit records are only written to the spool file, as no collector can be reached.
+	Spooling bool `json:"spooling"`
+	// Backends are the exporters of the failover, with their success and failure counts and last error.
+	Backends []backendStatus `json:"backends,omitempty"`
+	// Unacknowledged is the number of records in the write-ahead log that have not been exported yet.
+	Unacknowledged int `json:"unacknowledged"`
+	// BulkLogs is the number of bulk log records that have not been emitted yet.
+	BulkLogs int64 `json:"bulkLogs"`
+	// ConfigFile is set if the logger provider is configured by a file, which doesn't report its status.
+	ConfigFile string `json:"configFile,omitempty"`
+}
+
+func (p auditPipeline) status() pipelineStatus {
+	s := pipelineStatus{ConfigFile: getConfigFile()}
+	if p.failover != nil {
+		failingFor := p.failover.failingFor()
+		s.Ready = failingFor <= getReadinessThreshold()
+		s.FailingFor = failingFor.Seconds()
+		s.LastDelivered, s.Spooling = p.failover.delivery()
+		s.Backends = p.failover.status()
+	} else {
+		s.Ready = true
+	}
+	if p.wal != nil {
+		s.Unacknowledged = p.wal.unacknowledged()
+	}
+	if bulkLogs != nil {
+		s.BulkLogs = bulkLogs.Outstanding()
+	}
+	return s
+}
+
+// healthz reports that the service is alive.
+func healthz(w http.ResponseWriter, _ *http.Request) {
+	if _, err := io.WriteString(w, "ok\n"); err != nil {
+		log.Printf("Write failed: %v\n", err)
+	}
+}
+
+// recover tries to get the pipeline ready again, as no requests are routed to the service while it is not.
+// The unacknowledged records of the write-ahead log are replayed in the background, which clears the failure once
+// they reach a collector. If there are none, the collectors are probed, which clears the failure once one can be reached.
+func (p auditPipeline) recover(ctx context.Context) bool {
+	if p.wal != nil && p.wal.unacknowledged() > 0 {
+		p.wal.retry()
+		return false
+	}
+	if p.failover == nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	return p.failover.probe(ctx)
+}
+
+// probeTimeout is the time a readiness probe waits for a collector, which is below the default timeout of Kubernetes probes.
+const probeTimeout = 500 * time.Millisecond
+
+// readyz reports whether the service can audit its requests. It fails once the audit records have not been exported
+// to a collector for longer than the readiness threshold, even if they are spooled, so that no more requests are routed to the service.
+// As there are no more requests then, every failed probe tries to recover the pipeline,
+// so that the service gets ready again once the exporter has recovered.
+func readyz(w http.ResponseWriter, r *http.Request) {
+	if !pipeline.status().Ready && !pipeline.recover(r.Context()) {
+		http.Error(w, "audit records are not reaching a collector", http.StatusServiceUnavailable)
+		return
+	}
+	if _, err := io.WriteString(w, "ok\n"); err != nil {
+		log.Printf("Write failed: %v\n", err)
+	}
+}
+
+// debugOTel returns the status of the audit pipeline as JSON.
+func debugOTel(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(pipeline.status()); err != nil {
+		log.Printf("Write failed: %v\n", err)
+	}
+}
+
+const defaultReadinessThreshold = 30 * time.Second
+
+// getReadinessThreshold retrieves how long the audit exporter may fail before the service is not ready from the environment variable.
+// If the variable (READINESS_FAILURE_THRESHOLD) is not set or invalid, it returns the default threshold.
+func getReadinessThreshold() time.Duration {
+	if val, ok := os.LookupEnv("READINESS_FAILURE_THRESHOLD"); ok {
+		if d, err := time.ParseDuration(val); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return defaultReadinessThreshold
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// readyzCode returns the status code of /readyz.
+func readyzCode() int {
+	w := httptest.NewRecorder()
+	readyz(w, httptest.NewRequest("GET", "/readyz", nil))
+	return w.Code
+}
+
+func TestReadiness(t *testing.T) {
+	t.Setenv("READINESS_FAILURE_THRESHOLD", "0s")
+	errExport := errors.New("collector unavailable")
+	errUnreachable := errors.New("connection refused")
+
+	tests := []struct {
+		name string
+		// fail makes the collector fail, recover makes it available again.
+		fail, recover func(collector *fakeExporter, reachable *error)
+		// pending exports a record while the collector fails, which stays in the write-ahead log.
+		pending bool
+	}{
+		{
+			name: "replay of pending records",
+			fail: func(collector *fakeExporter, _ *error) { collector.setErr(errExport) },
+			recover: func(collector *fakeExporter, _ *error) {
+				collector.setErr(nil)
+			},
+			pending: true,
+		},
+		{
+			name: "probe without pending records",
+			fail: func(collector *fakeExporter, reachable *error) {
+				collector.setErr(errExport)
+				*reachable = errUnreachable
+			},
+			recover: func(collector *fakeExporter, reachable *error) {
+				collector.setErr(nil)
+				*reachable = nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector, spool := &fakeExporter{}, &fakeExporter{}
+			var reachable error
+			b := newBackend("otlpgrpc", collector)
+			b.probe = func(context.Context) error { return reachable }
+			failover := newFailoverExporter(10*time.Millisecond, b, newLocalBackend("spool", spool))
+			wal := openWAL(t, failover, t.TempDir())
+			defer wal.Shutdown(context.Background())
+			pipeline = auditPipeline{failover: failover, wal: wal}
+			defer func() { pipeline = auditPipeline{} }()
+
+			if code := readyzCode(); code != http.StatusOK {
+				t.Fatalf("readyz = %d before any export, want %d", code, http.StatusOK)
+			}
+
+			// The records are only spooled, so the service is not ready.
+			tt.fail(collector, &reachable)
+			if err := wal.Export(context.Background(), newRecords(t, "a")); !errors.Is(err, errSpooled) {
+				t.Fatalf("Export() error = %v, want %v", err, errSpooled)
+			}
+			if !tt.pending {
+				// The record is acknowledged, as if it had been exported, so that nothing is left to replay.
+				wal.ack(1)
+			}
+			if code := readyzCode(); code != http.StatusServiceUnavailable {
+				t.Errorf("readyz = %d while spooling, want %d", code, http.StatusServiceUnavailable)
+			}
+			if s := pipeline.status(); s.Ready || !s.Spooling {
+				t.Errorf("status = %+v, want not ready and spooling", s)
+			}
+
+			// Without requests, the probes get the service ready again once the collector has recovered.
+			tt.recover(collector, &reachable)
+			waitFor(t, "readiness", func() bool { return readyzCode() == http.StatusOK })
+			if got := wal.unacknowledged(); got != 0 {
+				t.Errorf("unacknowledged() = %d after recovery, want 0", got)
+			}
+			if s := pipeline.status(); !s.Ready || s.FailingFor != 0 || !s.Backends[0].Healthy {
+				t.Errorf("status = %+v, want ready with a healthy gRPC backend", s)
+			}
+		})
+	}
+}
+
+func TestDialProbe(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+
+	for _, endpoint := range []string{"http://" + address, address} {
+		if err := dialProbe(endpoint)(context.Background()); err != nil {
+			t.Errorf("probe of %s error = %v", endpoint, err)
+		}
+	}
+	l.Close()
+	if err := dialProbe("http://" + address)(context.Background()); err == nil {
+		t.Errorf("probe of closed %s succeeded", address)
+	}
+}
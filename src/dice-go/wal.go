@@ -0,0 +1,646 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	olog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// errWALClosed is returned by walExporter.Export after shutdown.
+var errWALClosed = errors.New("write-ahead log is closed")
+
+// maxSegmentSize is the size after which a new segment file is started.
+const maxSegmentSize = 8 << 20
+
+// walExporter is a write-ahead log in front of an exporter. Every record is appended to a segment file (and synced)
+// before it is exported, and acknowledged after it has been exported. Records that have not been acknowledged are
+// replayed at startup and whenever an export succeeds again, so that they survive collector outages and restarts.
+// Segments are deleted once all of their records have been acknowledged and all earlier segments have been deleted,
+// as a segment also holds the acknowledgements of records in earlier segments.
+//
+// Records are delivered at least once: a record is exported again if its acknowledgement was not written.
+// A must-deliver record whose export failed is refused instead, as its auditLogger refuses the action.
+type walExporter struct {
+	sdklog.Exporter
+	dir string
+	// replayProvider emits the replayed records with the resource of the service and the scope of the original record.
+	replayProvider *sdklog.LoggerProvider
+	ctx            context.Context
+	cancel         context.CancelFunc
+	replaying      atomic.Bool
+
+	mu       sync.Mutex
+	active   *walSegment
+	segments map[string]*walSegment
+	pending  map[uint64]walPending
+	// inflight holds the IDs of the records that are being exported by Export, which are not replayed.
+	inflight map[uint64]bool
+	nextID   uint64
+}
+
+// walSegment is a segment file of the write-ahead log.
+type walSegment struct {
+	name     string
+	file     *os.File
+	size     int64
+	unacked  int
+	complete bool
+}
+
+type walPending struct {
+	segment string
+	record  walRecord
+}
+
+// walEntry is a line of a segment file. It either holds a record or acknowledges one.
+// A refused record is acknowledged without having been exported.
+type walEntry struct {
+	ID      uint64     `json:"id,omitempty"`
+	Record  *walRecord `json:"record,omitempty"`
+	Ack     uint64     `json:"ack,omitempty"`
+	Refused bool       `json:"refused,omitempty"`
+}
+
+// walRecord is the serialized form of an sdklog.Record.
+type walRecord struct {
+	ScopeName         string    `json:"scopeName"`
+	ScopeVersion      string    `json:"scopeVersion,omitempty"`
+	ScopeSchemaURL    string    `json:"scopeSchemaUrl,omitempty"`
+	ScopeAttributes   []walKV   `json:"scopeAttributes,omitempty"`
+	EventName         string    `json:"eventName,omitempty"`
+	Timestamp         time.Time `json:"timestamp"`
+	ObservedTimestamp time.Time `json:"observedTimestamp"`
+	Severity          int       `json:"severity"`
+	SeverityText      string    `json:"severityText,omitempty"`
+	Body              *walValue `json:"body,omitempty"`
+	Attributes        []walKV   `json:"attributes,omitempty"`
+	TraceID           string    `json:"traceId,omitempty"`
+	SpanID            string    `json:"spanId,omitempty"`
+	TraceFlags        byte      `json:"traceFlags,omitempty"`
+}
+
+type walKV struct {
+	Key   string   `json:"key"`
+	Value walValue `json:"value"`
+}
+
+// walValue is an olog.Value that keeps its kind when serialized.
+type walValue struct {
+	Bool   *bool       `json:"bool,omitempty"`
+	Int    *int64      `json:"int,omitempty"`
+	Float  *float64    `json:"float,omitempty"`
+	String *string     `json:"string,omitempty"`
+	Bytes  []byte      `json:"bytes,omitempty"`
+	Slice  *[]walValue `json:"slice,omitempty"`
+	Map    *[]walKV    `json:"map,omitempty"`
+}
+
+// newWALExporter opens the write-ahead log in dir in front of exporter and replays its unacknowledged records.
+func newWALExporter(exporter sdklog.Exporter, dir string, res *sdkresource.Resource) (*walExporter, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create WAL directory: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &walExporter{
+		Exporter: exporter,
+		dir:      dir,
+		ctx:      ctx,
+		cancel:   cancel,
+		segments: map[string]*walSegment{},
+		pending:  map[uint64]walPending{},
+		inflight: map[uint64]bool{},
+		nextID:   1,
+	}
+	w.replayProvider = sdklog.NewLoggerProvider(
+		sdklog.WithResource(res),
+		sdklog.WithProcessor(&walReplayProcessor{wal: w}),
+	)
+
+	if err := w.load(); err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := w.rotate(); err != nil {
+		cancel()
+		return nil, err
+	}
+	go w.replay()
+	return w, nil
+}
+
+// load reads the existing segments and deletes those without unacknowledged records.
+func (w *walExporter) load() error {
+	names, err := filepath.Glob(filepath.Join(w.dir, "*.wal"))
+	if err != nil {
+		return err
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		seg := &walSegment{name: name, complete: true}
+		w.segments[name] = seg
+		if err := w.loadSegment(seg); err != nil {
+			return fmt.Errorf("failed to read WAL segment %s: %w", name, err)
+		}
+	}
+	w.removeDone()
+	return nil
+}
+
+func (w *walExporter) loadSegment(seg *walSegment) error {
+	f, err := os.Open(seg.name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		var entry walEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// The last line may be incomplete after a crash.
+			continue
+		}
+		switch {
+		case entry.Record != nil:
+			w.pending[entry.ID] = walPending{segment: seg.name, record: *entry.Record}
+			seg.unacked++
+			w.nextID = max(w.nextID, entry.ID+1)
+		case entry.Ack != 0:
+			if p, ok := w.pending[entry.Ack]; ok {
+				delete(w.pending, entry.Ack)
+				if s, ok := w.segments[p.segment]; ok {
+					s.unacked--
+				}
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// rotate closes the active segment and starts a new one.
+func (w *walExporter) rotate() error {
+	if w.active != nil {
+		w.active.complete = true
+		if err := w.active.file.Close(); err != nil {
+			return err
+		}
+		w.removeDone()
+	}
+	name := filepath.Join(w.dir, fmt.Sprintf("%020d.wal", w.nextID))
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create WAL segment: %w", err)
+	}
+	w.active = &walSegment{name: name, file: f}
+	w.segments[name] = w.active
+	return nil
+}
+
+// removeDone deletes the oldest segments as long as all of their records have been acknowledged.
+// A later segment is kept even if it is done, until all earlier ones are: it may hold the acknowledgements
+// of records in an earlier segment, which would be replayed again after a restart if they were deleted.
+func (w *walExporter) removeDone() {
+	// The segments are named after the ID of their first record, so they sort in the order they have been written.
+	for _, name := range slices.Sorted(maps.Keys(w.segments)) {
+		seg := w.segments[name]
+		if !seg.complete || seg.unacked > 0 {
+			return
+		}
+		if err := os.Remove(seg.name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			otel.Handle(fmt.Errorf("failed to remove WAL segment: %w", err))
+			return
+		}
+		delete(w.segments, name)
+	}
+}
+
+// append writes the records to the active segment and syncs it. It returns the IDs of the records.
+func (w *walExporter) append(records []sdklog.Record) ([]uint64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.active == nil {
+		return nil, errWALClosed
+	}
+	if w.active.size >= maxSegmentSize {
+		if err := w.rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf []byte
+	ids := make([]uint64, 0, len(records))
+	recs := make([]walRecord, 0, len(records))
+	for i := range records {
+		id := w.nextID
+		w.nextID++
+		rec := toWALRecord(&records[i])
+		line, err := json.Marshal(walEntry{ID: id, Record: &rec})
+		if err != nil {
+			return nil, err
+		}
+		buf = append(append(buf, line...), '\n')
+		ids = append(ids, id)
+		recs = append(recs, rec)
+	}
+	n, err := w.active.file.Write(buf)
+	if err != nil {
+		err = fmt.Errorf("failed to write WAL: %w", err)
+	} else if err = w.active.file.Sync(); err != nil {
+		err = fmt.Errorf("failed to sync WAL: %w", err)
+	}
+	if err != nil {
+		// The records are reported as not exported, so they must not be replayed after a restart either.
+		if tErr := w.active.file.Truncate(w.active.size); tErr != nil {
+			w.active.size += int64(n)
+			otel.Handle(fmt.Errorf("failed to truncate WAL segment: %w", tErr))
+		}
+		return nil, err
+	}
+
+	// The records are only registered once they are on disk.
+	w.active.size += int64(n)
+	for i, id := range ids {
+		w.pending[id] = walPending{segment: w.active.name, record: recs[i]}
+		w.inflight[id] = true
+		w.active.unacked++
+	}
+	return ids, nil
+}
+
+// ack marks the records as exported. Acknowledgements are not synced, as losing one only causes a duplicate.
+func (w *walExporter) ack(ids ...uint64) {
+	w.remove(false, ids)
+}
+
+// refuse marks the records as refused, so that they are never replayed. Unlike acknowledgements, refusals are synced,
+// as a replayed refused record would audit an action that never happened.
+func (w *walExporter) refuse(ids ...uint64) {
+	w.remove(true, ids)
+}
+
+func (w *walExporter) remove(refused bool, ids []uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.active == nil {
+		return
+	}
+	var buf []byte
+	for _, id := range ids {
+		p, ok := w.pending[id]
+		if !ok {
+			continue
+		}
+		delete(w.pending, id)
+		line, _ := json.Marshal(walEntry{Ack: id, Refused: refused})
+		buf = append(append(buf, line...), '\n')
+		if seg, ok := w.segments[p.segment]; ok {
+			seg.unacked--
+		}
+	}
+	if len(buf) == 0 {
+		return
+	}
+	n, err := w.active.file.Write(buf)
+	w.active.size += int64(n)
+	if err == nil && refused {
+		err = w.active.file.Sync()
+	}
+	if err != nil {
+		otel.Handle(fmt.Errorf("failed to acknowledge WAL records: %w", err))
+		return
+	}
+	w.removeDone()
+}
+
+// Export appends the records to the write-ahead log before exporting them. After a successful export,
+// the records that failed before are replayed.
+func (w *walExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	ids, err := w.append(records)
+	if err != nil {
+		return err
+	}
+	err = w.Exporter.Export(ctx, records)
+
+	w.mu.Lock()
+	for _, id := range ids {
+		delete(w.inflight, id)
+	}
+	w.mu.Unlock()
+	if err != nil {
+		// The auditLogger of a must-deliver record refuses the action, so the record must not be replayed.
+		// A spooled record is accepted, but stays unacknowledged until it has been exported to a collector.
+		if mustDeliver(ctx) && !errors.Is(err, errSpooled) {
+			w.refuse(ids...)
+		}
+		return err
+	}
+	w.ack(ids...)
+
+	w.mu.Lock()
+	pending := len(w.pending)
+	w.mu.Unlock()
+	if pending > 0 {
+		go w.replay()
+	}
+	return nil
+}
+
+// replay exports the unacknowledged records in order until an export fails.
+func (w *walExporter) replay() {
+	if !w.replaying.CompareAndSwap(false, true) {
+		return
+	}
+	defer w.replaying.Store(false)
+
+	w.mu.Lock()
+	ids := slices.Sorted(func(yield func(uint64) bool) {
+		for id := range w.pending {
+			if !yield(id) {
+				return
+			}
+		}
+	})
+	w.mu.Unlock()
+
+	for _, id := range ids {
+		if w.ctx.Err() != nil {
+			return
+		}
+		w.mu.Lock()
+		p, ok := w.pending[id]
+		inflight := w.inflight[id]
+		w.mu.Unlock()
+		if !ok || inflight {
+			continue
+		}
+
+		// The result stays an error if the record is not exported, because the replay provider has been shut down.
+		result := &walReplayResult{err: errWALClosed}
+		ctx := context.WithValue(w.ctx, walReplayKey{}, result)
+		rec, ctx := p.record.toLogRecord(ctx)
+		w.replayProvider.Logger(p.record.ScopeName,
+			olog.WithInstrumentationVersion(p.record.ScopeVersion),
+			olog.WithSchemaURL(p.record.ScopeSchemaURL),
+			olog.WithInstrumentationAttributes(toAttributes(p.record.ScopeAttributes)...),
+		).Emit(ctx, rec)
+		if result.err != nil {
+			return
+		}
+		w.ack(id)
+	}
+}
+
+// retryEvery replays the unacknowledged records every interval until the write-ahead log is shut down,
+// so that they are retried forever, even if no more records are exported.
+func (w *walExporter) retryEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			w.retry()
+		}
+	}
+}
+
+// retry replays the unacknowledged records in the background, if there are any.
+func (w *walExporter) retry() {
+	if w.unacknowledged() > 0 {
+		go w.replay()
+	}
+}
+
+// unacknowledged returns the number of records that have not been acknowledged yet, including the ones being exported.
+func (w *walExporter) unacknowledged() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.pending)
+}
+
+// Shutdown stops the replay and closes the active segment. Unacknowledged records are replayed at the next start.
+func (w *walExporter) Shutdown(ctx context.Context) error {
+	w.cancel()
+	err := errors.Join(w.replayProvider.Shutdown(ctx), w.Exporter.Shutdown(ctx))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.active != nil {
+		err = errors.Join(err, w.active.file.Close())
+		w.active.complete = true
+		w.removeDone()
+		w.active = nil
+	}
+	return err
+}
+
+// walReplayKey is the context key of the walReplayResult of a replayed record.
+type walReplayKey struct{}
+
+type walReplayResult struct {
+	err error
+}
+
+// isReplay reports whether the records exported with ctx are replayed by a walExporter.
+func isReplay(ctx context.Context) bool {
+	_, ok := ctx.Value(walReplayKey{}).(*walReplayResult)
+	return ok
+}
+
+// walReplayProcessor exports the records replayed by a walExporter with its exporter, bypassing the write-ahead log.
+type walReplayProcessor struct {
+	wal *walExporter
+}
+
+func (*walReplayProcessor) Enabled(context.Context, sdklog.EnabledParameters) bool {
+	return true
+}
+
+func (p *walReplayProcessor) OnEmit(ctx context.Context, rec *sdklog.Record) error {
+	err := p.wal.Exporter.Export(ctx, []sdklog.Record{*rec})
+	if result, ok := ctx.Value(walReplayKey{}).(*walReplayResult); ok {
+		result.err = err
+	}
+	return nil
+}
+
+func (*walReplayProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (*walReplayProcessor) ForceFlush(context.Context) error {
+	return nil
+}
+
+func toWALRecord(rec *sdklog.Record) walRecord {
+	scope := rec.InstrumentationScope()
+	r := walRecord{
+		ScopeName:         scope.Name,
+		ScopeVersion:      scope.Version,
+		ScopeSchemaURL:    scope.SchemaURL,
+		EventName:         rec.EventName(),
+		Timestamp:         rec.Timestamp(),
+		ObservedTimestamp: rec.ObservedTimestamp(),
+		Severity:          int(rec.Severity()),
+		SeverityText:      rec.SeverityText(),
+		TraceFlags:        byte(rec.TraceFlags()),
+	}
+	for _, kv := range scope.Attributes.ToSlice() {
+		r.ScopeAttributes = append(r.ScopeAttributes, walKV{Key: string(kv.Key), Value: toWALValue(olog.KeyValueFromAttribute(kv).Value)})
+	}
+	if body := rec.Body(); body.Kind() != olog.KindEmpty {
+		v := toWALValue(body)
+		r.Body = &v
+	}
+	rec.WalkAttributes(func(kv olog.KeyValue) bool {
+		r.Attributes = append(r.Attributes, walKV{Key: kv.Key, Value: toWALValue(kv.Value)})
+		return true
+	})
+	if rec.TraceID().IsValid() {
+		r.TraceID = rec.TraceID().String()
+	}
+	if rec.SpanID().IsValid() {
+		r.SpanID = rec.SpanID().String()
+	}
+	return r
+}
+
+// toLogRecord returns the record to replay. The trace context of the record is set on ctx.
+func (r walRecord) toLogRecord(ctx context.Context) (olog.Record, context.Context) {
+	rec := olog.Record{}
+	rec.SetEventName(r.EventName)
+	rec.SetTimestamp(r.Timestamp)
+	rec.SetObservedTimestamp(r.ObservedTimestamp)
+	rec.SetSeverity(olog.Severity(r.Severity))
+	rec.SetSeverityText(r.SeverityText)
+	if r.Body != nil {
+		rec.SetBody(r.Body.toValue())
+	}
+	for _, kv := range r.Attributes {
+		rec.AddAttributes(olog.KeyValue{Key: kv.Key, Value: kv.Value.toValue()})
+	}
+
+	var sc trace.SpanContextConfig
+	if b, err := hex.DecodeString(r.TraceID); err == nil {
+		copy(sc.TraceID[:], b)
+	}
+	if b, err := hex.DecodeString(r.SpanID); err == nil {
+		copy(sc.SpanID[:], b)
+	}
+	sc.TraceFlags = trace.TraceFlags(r.TraceFlags)
+	return rec, trace.ContextWithSpanContext(ctx, trace.NewSpanContext(sc))
+}
+
+func toWALValue(v olog.Value) walValue {
+	switch v.Kind() {
+	case olog.KindBool:
+		b := v.AsBool()
+		return walValue{Bool: &b}
+	case olog.KindInt64:
+		n := v.AsInt64()
+		return walValue{Int: &n}
+	case olog.KindFloat64:
+		f := v.AsFloat64()
+		return walValue{Float: &f}
+	case olog.KindString:
+		s := v.AsString()
+		return walValue{String: &s}
+	case olog.KindBytes:
+		return walValue{Bytes: v.AsBytes()}
+	case olog.KindSlice:
+		values := []walValue{}
+		for _, item := range v.AsSlice() {
+			values = append(values, toWALValue(item))
+		}
+		return walValue{Slice: &values}
+	case olog.KindMap:
+		values := []walKV{}
+		for _, kv := range v.AsMap() {
+			values = append(values, walKV{Key: kv.Key, Value: toWALValue(kv.Value)})
+		}
+		return walValue{Map: &values}
+	default:
+		return walValue{}
+	}
+}
+
+func (v walValue) toValue() olog.Value {
+	switch {
+	case v.Bool != nil:
+		return olog.BoolValue(*v.Bool)
+	case v.Int != nil:
+		return olog.Int64Value(*v.Int)
+	case v.Float != nil:
+		return olog.Float64Value(*v.Float)
+	case v.String != nil:
+		return olog.StringValue(*v.String)
+	case v.Bytes != nil:
+		return olog.BytesValue(v.Bytes)
+	case v.Slice != nil:
+		values := make([]olog.Value, 0, len(*v.Slice))
+		for _, item := range *v.Slice {
+			values = append(values, item.toValue())
+		}
+		return olog.SliceValue(values...)
+	case v.Map != nil:
+		values := make([]olog.KeyValue, 0, len(*v.Map))
+		for _, kv := range *v.Map {
+			values = append(values, olog.KeyValue{Key: kv.Key, Value: kv.Value.toValue()})
+		}
+		return olog.MapValue(values...)
+	default:
+		return olog.Value{}
+	}
+}
+
+// toAttributes converts the scope attributes back. Values that are not scalar are kept as strings.
+func toAttributes(kvs []walKV) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(kvs))
+	for _, kv := range kvs {
+		switch v := kv.Value; {
+		case v.Bool != nil:
+			attrs = append(attrs, attribute.Bool(kv.Key, *v.Bool))
+		case v.Int != nil:
+			attrs = append(attrs, attribute.Int64(kv.Key, *v.Int))
+		case v.Float != nil:
+			attrs = append(attrs, attribute.Float64(kv.Key, *v.Float))
+		case v.String != nil:
+			attrs = append(attrs, attribute.String(kv.Key, *v.String))
+		default:
+			attrs = append(attrs, attribute.String(kv.Key, v.toValue().String()))
+		}
+	}
+	return attrs
+}
+
+const defaultWALDir = "wal"
+
+// getWALDir retrieves the directory of the write-ahead logs from the environment variable.
+// If the variable (AUDIT_WAL_DIR) is not set, it returns the default directory.
+func getWALDir() string {
+	if val, ok := os.LookupEnv("AUDIT_WAL_DIR"); ok && val != "" {
+		return val
+	}
+	return defaultWALDir
+}
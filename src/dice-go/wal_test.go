@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	olog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+)
+
+// newRecords returns records with the bodies, emitted by the audit logger of the service.
+func newRecords(t *testing.T, bodies ...string) []sdklog.Record {
+	t.Helper()
+	recorder := &recordingProcessor{}
+	logger := sdklog.NewLoggerProvider(sdklog.WithProcessor(recorder)).Logger("DICE_GO_SERVICE")
+	for _, body := range bodies {
+		rec := olog.Record{}
+		rec.SetBody(olog.StringValue(body))
+		rec.AddAttributes(olog.Int("dice.result", 42))
+		logger.Emit(context.Background(), rec)
+	}
+	return recorder.records
+}
+
+// bodies returns the bodies of the records exported by e.
+func (e *fakeExporter) bodies() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var bodies []string
+	for _, rec := range e.records {
+		bodies = append(bodies, rec.Body().AsString())
+	}
+	return bodies
+}
+
+// waitFor fails the test if cond does not become true within a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func openWAL(t *testing.T, exporter sdklog.Exporter, dir string) *walExporter {
+	t.Helper()
+	w, err := newWALExporter(exporter, dir, sdkresource.Empty())
+	if err != nil {
+		t.Fatalf("newWALExporter() error = %v", err)
+	}
+	return w
+}
+
+// rotateForTest starts a new segment, as if the active one had exceeded maxSegmentSize.
+func (w *walExporter) rotateForTest(t *testing.T) {
+	t.Helper()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.rotate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWALExporter(t *testing.T) {
+	errExport := errors.New("collector unavailable")
+	mustDeliverCtx := context.WithValue(context.Background(), deliveryResultKey{}, &deliveryResult{})
+
+	tests := []struct {
+		name string
+		// run exports records with the write-ahead log before the restart.
+		run func(t *testing.T, w *walExporter, exporter *fakeExporter)
+		// crash modifies the directory after the shutdown, as a crash would.
+		crash       func(t *testing.T, dir string)
+		wantPending int
+		// wantReplayed are the bodies of the records replayed after the restart.
+		wantReplayed []string
+	}{
+		{
+			name: "exported records are acknowledged",
+			run: func(t *testing.T, w *walExporter, _ *fakeExporter) {
+				if err := w.Export(context.Background(), newRecords(t, "a", "b")); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "failed records are replayed after a restart",
+			run: func(t *testing.T, w *walExporter, exporter *fakeExporter) {
+				exporter.setErr(errExport)
+				if err := w.Export(context.Background(), newRecords(t, "a", "b")); !errors.Is(err, errExport) {
+					t.Fatalf("Export() error = %v, want %v", err, errExport)
+				}
+			},
+			wantPending:  2,
+			wantReplayed: []string{"a", "b"},
+		},
+		{
+			name: "failed records are replayed after the next export",
+			run: func(t *testing.T, w *walExporter, exporter *fakeExporter) {
+				exporter.setErr(errExport)
+				_ = w.Export(context.Background(), newRecords(t, "a"))
+				exporter.setErr(nil)
+				if err := w.Export(context.Background(), newRecords(t, "b")); err != nil {
+					t.Fatal(err)
+				}
+				waitFor(t, "the replay", func() bool { return w.unacknowledged() == 0 })
+				// a is exported again, either by the replay after b or by the one at startup.
+				if got, want := slices.Sorted(slices.Values(exporter.bodies())), []string{"a", "a", "b"}; !slices.Equal(got, want) {
+					t.Errorf("exported %q, want %q", got, want)
+				}
+			},
+		},
+		{
+			name: "failed must-deliver records are refused",
+			run: func(t *testing.T, w *walExporter, exporter *fakeExporter) {
+				exporter.setErr(errExport)
+				_ = w.Export(mustDeliverCtx, newRecords(t, "a"))
+			},
+		},
+		{
+			name: "spooled must-deliver records stay pending",
+			run: func(t *testing.T, w *walExporter, exporter *fakeExporter) {
+				exporter.setErr(errors.Join(errSpooled, errExport))
+				_ = w.Export(mustDeliverCtx, newRecords(t, "a"))
+			},
+			wantPending:  1,
+			wantReplayed: []string{"a"},
+		},
+		{
+			name: "torn last line",
+			run: func(t *testing.T, w *walExporter, exporter *fakeExporter) {
+				exporter.setErr(errExport)
+				_ = w.Export(context.Background(), newRecords(t, "a"))
+			},
+			crash: func(t *testing.T, dir string) {
+				names, _ := filepath.Glob(filepath.Join(dir, "*.wal"))
+				f, err := os.OpenFile(names[len(names)-1], os.O_WRONLY|os.O_APPEND, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer f.Close()
+				if _, err := f.WriteString(`{"id":99,"record":{"scopeName":"DICE_GO_SER`); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantPending:  1,
+			wantReplayed: []string{"a"},
+		},
+		{
+			name: "acknowledged segments are deleted",
+			run: func(t *testing.T, w *walExporter, _ *fakeExporter) {
+				for _, body := range []string{"a", "b", "c"} {
+					if err := w.Export(context.Background(), newRecords(t, body)); err != nil {
+						t.Fatal(err)
+					}
+					w.rotateForTest(t)
+				}
+				if names, _ := filepath.Glob(filepath.Join(w.dir, "*.wal")); len(names) != 1 {
+					t.Errorf("got segments %q, want only the active one", names)
+				}
+			},
+		},
+		{
+			// The acknowledgement of b is in the second segment, which is done, but must be kept as long as a is pending.
+			name: "acknowledgements of records in older segments are kept",
+			run: func(t *testing.T, w *walExporter, exporter *fakeExporter) {
+				exporter.setErr(errExport)
+				_ = w.Export(context.Background(), newRecords(t, "a", "b"))
+				w.rotateForTest(t)
+				w.mu.Lock()
+				ids := slices.Sorted(maps.Keys(w.pending))
+				w.mu.Unlock()
+				w.ack(ids[1])
+				w.rotateForTest(t)
+			},
+			wantPending:  1,
+			wantReplayed: []string{"a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			exporter := &fakeExporter{}
+			w := openWAL(t, exporter, dir)
+			tt.run(t, w, exporter)
+			if got := w.unacknowledged(); got != tt.wantPending {
+				t.Errorf("unacknowledged() = %d, want %d", got, tt.wantPending)
+			}
+			if err := w.Shutdown(context.Background()); err != nil {
+				t.Fatalf("Shutdown() error = %v", err)
+			}
+			if !exporter.shutdown {
+				t.Error("exporter has not been shut down")
+			}
+			if w.replayProvider.Logger("DICE_GO_SERVICE").Enabled(context.Background(), olog.EnabledParameters{}) {
+				t.Error("replay provider has not been shut down")
+			}
+			if tt.crash != nil {
+				tt.crash(t, dir)
+			}
+
+			// Restart with a collector that is available again.
+			exporter = &fakeExporter{}
+			w = openWAL(t, exporter, dir)
+			defer w.Shutdown(context.Background())
+			waitFor(t, "the replay", func() bool { return w.unacknowledged() == 0 })
+			if got := exporter.bodies(); !slices.Equal(got, tt.wantReplayed) {
+				t.Errorf("replayed %q, want %q", got, tt.wantReplayed)
+			}
+		})
+	}
+}
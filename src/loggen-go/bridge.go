@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/contrib/bridges/otellogr"
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/contrib/bridges/otelzap"
+	olog "go.opentelemetry.io/otel/log"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// The loggers of the bridges use the global LoggerProvider, like logger.
+var (
+	bridgeName = flag.String("bridge", "direct", "logging API to emit the records through: "+strings.Join(bridgeNames(), ", "))
+	// emitRecord is the bridge selected by -bridge.
+	emitRecord bridge
+
+	slogLogger = otelslog.NewLogger("loggen-go")
+	logrLogger = logr.New(otellogr.NewLogSink("loggen-go"))
+	zapLogger  = zap.New(otelzap.NewCore("loggen-go"))
+)
+
+// bridge emits a generated record through a logging API.
+type bridge func(ctx context.Context, rec olog.Record)
+
+// bridges are the logging APIs records can be emitted through. Apart from direct, they can't express every
+// record exactly, so that the verification shows what a bridge loses compared to the Logs API:
+//   - The body is passed as message. A body that is not a string is formatted as string.
+//   - The event name is dropped.
+var bridges = map[string]bridge{
+	"direct": func(ctx context.Context, rec olog.Record) { logger.Emit(ctx, rec) },
+	"slog":   emitSlog,
+	"logr":   emitLogr,
+	"zap":    emitZap,
+}
+
+func bridgeNames() []string {
+	return slices.Sorted(maps.Keys(bridges))
+}
+
+func lookupBridge(name string) (bridge, error) {
+	b, ok := bridges[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown bridge %q, available bridges: %v", name, bridgeNames())
+	}
+	return b, nil
+}
+
+// emitSlog emits rec through otelslog. The slog levels map to all severities, as otelslog adds 9 to the level.
+func emitSlog(ctx context.Context, rec olog.Record) {
+	attrs := make([]slog.Attr, 0, rec.AttributesLen())
+	rec.WalkAttributes(func(kv olog.KeyValue) bool {
+		attrs = append(attrs, slog.Any(kv.Key, canonicalValue(kv.Value)))
+		return true
+	})
+	level := slog.Level(rec.Severity() - olog.SeverityInfo)
+	slogLogger.LogAttrs(ctx, level, message(rec), attrs...)
+}
+
+// emitLogr emits rec through otellogr. The context is passed as a value, as logr has no context parameter.
+// Records with severity error or above are logged as errors, which adds an exception.message attribute.
+// Other severities are mapped to the V-levels info, debug (V(1)) and trace (V(2)), so warnings become info.
+func emitLogr(ctx context.Context, rec olog.Record) {
+	keysAndValues := make([]any, 0, 2*rec.AttributesLen()+2)
+	rec.WalkAttributes(func(kv olog.KeyValue) bool {
+		keysAndValues = append(keysAndValues, kv.Key, canonicalValue(kv.Value))
+		return true
+	})
+	keysAndValues = append(keysAndValues, "ctx", ctx)
+
+	msg := message(rec)
+	switch sev := rec.Severity(); {
+	case sev >= olog.SeverityError:
+		logrLogger.Error(errors.New(msg), msg, keysAndValues...)
+	case sev >= olog.SeverityInfo:
+		logrLogger.Info(msg, keysAndValues...)
+	case sev >= olog.SeverityDebug:
+		logrLogger.V(1).Info(msg, keysAndValues...)
+	default:
+		logrLogger.V(2).Info(msg, keysAndValues...)
+	}
+}
+
+// emitZap emits rec through otelzap. The context is passed as a field, as zap has no context parameter.
+// Only the zap levels debug to error are used, as the higher levels panic or exit.
+func emitZap(ctx context.Context, rec olog.Record) {
+	fields := make([]zap.Field, 0, rec.AttributesLen()+1)
+	rec.WalkAttributes(func(kv olog.KeyValue) bool {
+		fields = append(fields, zap.Any(kv.Key, canonicalValue(kv.Value)))
+		return true
+	})
+	fields = append(fields, zap.Any("ctx", ctx))
+
+	level := zapcore.DebugLevel
+	switch sev := rec.Severity(); {
+	case sev >= olog.SeverityError:
+		level = zapcore.ErrorLevel
+	case sev >= olog.SeverityWarn:
+		level = zapcore.WarnLevel
+	case sev >= olog.SeverityInfo:
+		level = zapcore.InfoLevel
+	}
+	if ce := zapLogger.Check(level, message(rec)); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+// message returns the body of rec as log message.
+func message(rec olog.Record) string {
+	switch body := rec.Body(); body.Kind() {
+	case olog.KindEmpty:
+		return ""
+	case olog.KindString:
+		return body.AsString()
+	default:
+		return body.String()
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	olog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/global"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// recordingProcessor keeps the emitted records.
+type recordingProcessor struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (p *recordingProcessor) OnEmit(_ context.Context, rec *sdklog.Record) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.records = append(p.records, rec.Clone())
+	return nil
+}
+
+func (p *recordingProcessor) take() []sdklog.Record {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	records := p.records
+	p.records = nil
+	return records
+}
+
+func (p *recordingProcessor) Enabled(context.Context, sdklog.EnabledParameters) bool { return true }
+func (p *recordingProcessor) Shutdown(context.Context) error                         { return nil }
+func (p *recordingProcessor) ForceFlush(context.Context) error                       { return nil }
+
+func TestBridgesPropagateSpanContext(t *testing.T) {
+	// The loggers of the bridges delegate to the global LoggerProvider once it is set.
+	processor := &recordingProcessor{}
+	global.SetLoggerProvider(sdklog.NewLoggerProvider(sdklog.WithProcessor(processor)))
+
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+		SpanID:     trace.SpanID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), spanContext)
+
+	for _, name := range bridgeNames() {
+		t.Run(name, func(t *testing.T) {
+			emit, err := lookupBridge(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rec := olog.Record{}
+			rec.SetSeverity(olog.SeverityInfo)
+			rec.SetBody(olog.StringValue("test"))
+			rec.AddAttributes(olog.String(sequenceKey, "1"))
+			emit(ctx, rec)
+
+			records := processor.take()
+			if len(records) != 1 {
+				t.Fatalf("got %d records, want 1", len(records))
+			}
+			got := records[0]
+			if got.TraceID() != spanContext.TraceID() {
+				t.Errorf("trace ID = %v, want %v", got.TraceID(), spanContext.TraceID())
+			}
+			if got.SpanID() != spanContext.SpanID() {
+				t.Errorf("span ID = %v, want %v", got.SpanID(), spanContext.SpanID())
+			}
+			got.WalkAttributes(func(kv olog.KeyValue) bool {
+				if kv.Value.Kind() == olog.KindEmpty {
+					t.Errorf("unexpected empty attribute %q", kv.Key)
+				}
+				return true
+			})
+		})
+	}
+}
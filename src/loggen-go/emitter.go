@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	olog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// emitter emits generated log records with increasing sequence numbers and adds them to the manifest of the run.
+// The phases of a run can be paused and their rate can be changed while they are running.
+type emitter struct {
+	runID    string
+	manifest *manifest
+
+	seq    atomic.Int64
+	paused atomic.Bool
+	// rate is the current target rate as float64 bits.
+	rate atomic.Uint64
+
+	mu         sync.Mutex
+	phase      string
+	rateChange *float64
+	stats      []phaseStats
+}
+
+func newEmitter(runID string, m *manifest) *emitter {
+	return &emitter{runID: runID, manifest: m}
+}
+
+// emit generates a record with the next sequence number using tmpl and emits it.
+// The record is emitted through the bridge selected by -bridge.
+// With -spans, the record is emitted inside a child span of ctx, so that it is correlated with the span.
+func (e *emitter) emit(ctx context.Context, tmpl template) {
+	seq := int(e.seq.Add(1))
+
+	if *withSpans {
+		var span trace.Span
+		ctx, span = tracer.Start(ctx, "emit", trace.WithAttributes(
+			attribute.Int(sequenceKey, seq),
+			attribute.String(runIDKey, e.runID),
+		))
+		defer span.End()
+	}
+
+	rec := olog.Record{}
+	// Add the sequence number first, so that it survives the attribute count limit of the SDK.
+	rec.AddAttributes(
+		olog.KeyValueFromAttribute(attribute.String(sequenceKey, strconv.Itoa(seq))),
+		olog.KeyValueFromAttribute(attribute.String(runIDKey, e.runID)),
+	)
+	tmpl(&rec, seq)
+	if err := e.manifest.add(seq, &rec, trace.SpanContextFromContext(ctx)); err != nil {
+		log.Printf("failed to hash record %d: %v", seq, err)
+	}
+	emitRecord(ctx, rec)
+}
+
+// pause stops the emission until resume is called.
+func (e *emitter) pause() { e.paused.Store(true) }
+
+// resume continues a paused emission.
+func (e *emitter) resume() { e.paused.Store(false) }
+
+// setRate changes the rate of the current phase. A ramp of the phase is stopped.
+// The change only applies to the current phase, the next phase starts with its own rate.
+func (e *emitter) setRate(rate float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rateChange = &rate
+	e.rate.Store(math.Float64bits(rate))
+}
+
+func (e *emitter) takeRateChange() (rate float64, ok bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.rateChange == nil {
+		return 0, false
+	}
+	rate, e.rateChange = *e.rateChange, nil
+	return rate, true
+}
+
+// progress describes how far a run has come.
+type progress struct {
+	Phase   string       `json:"phase"`
+	Rate    float64      `json:"rate"`
+	Paused  bool         `json:"paused"`
+	Emitted int          `json:"emitted"`
+	Phases  []phaseStats `json:"phases"`
+}
+
+func (e *emitter) progress() progress {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return progress{
+		Phase:   e.phase,
+		Rate:    math.Float64frombits(e.rate.Load()),
+		Paused:  e.paused.Load(),
+		Emitted: int(e.seq.Load()),
+		Phases:  slices.Clone(e.stats),
+	}
+}
+
+// runPhases runs the phases in order until all of them are done or ctx is canceled.
+func (e *emitter) runPhases(ctx context.Context, phases []phase) ([]phaseStats, error) {
+	for _, p := range phases {
+		if ctx.Err() != nil {
+			break
+		}
+
+		// A rate change of the previous phase must not carry over.
+		e.mu.Lock()
+		e.phase = p.Name
+		e.rateChange = nil
+		e.mu.Unlock()
+
+		stats, err := e.runPhase(ctx, p)
+		if err != nil {
+			return e.progress().Phases, err
+		}
+		fmt.Println(stats)
+
+		e.mu.Lock()
+		e.stats = append(e.stats, stats)
+		e.mu.Unlock()
+	}
+	return e.progress().Phases, nil
+}
+
+// runPhase emits records as described by p until its duration has elapsed, its count has been reached
+// or ctx is canceled.
+func (e *emitter) runPhase(ctx context.Context, p phase) (phaseStats, error) {
+	tmpl, err := lookupTemplate(p.Template)
+	if err != nil {
+		return phaseStats{}, err
+	}
+
+	stats := phaseStats{Name: p.Name}
+	start := time.Now()
+	if p.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Duration)
+		defer cancel()
+	}
+
+	e.rate.Store(math.Float64bits(p.Rate))
+	if p.idle() {
+		<-ctx.Done()
+		stats.Elapsed = time.Since(start)
+		return stats, nil
+	}
+
+	// The records are emitted in the context of a phase span (with -spans), but without the deadline of the phase,
+	// which would cancel their export.
+	spanCtx := context.Background()
+	if *withSpans {
+		var span trace.Span
+		spanCtx, span = tracer.Start(spanCtx, "phase "+p.Name, trace.WithAttributes(attribute.String(runIDKey, e.runID)))
+		defer span.End()
+	}
+
+	tokens := make(chan struct{})
+	go e.pace(ctx, p, tokens)
+
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		wg        sync.WaitGroup
+	)
+	for range max(p.Concurrency, 1) {
+		wg.Go(func() {
+			for range tokens {
+				emitStart := time.Now()
+				e.emit(spanCtx, tmpl)
+				latency := time.Since(emitStart)
+
+				mu.Lock()
+				latencies = append(latencies, latency)
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+
+	stats.Elapsed = time.Since(start)
+	stats.record(latencies)
+	return stats, nil
+}
+
+// pace sends a token for every record to emit into tokens, following the rate of p.
+// Pausing does not extend the duration of the phase, but the time spent paused doesn't count towards the rate.
+// It closes tokens when the phase is over.
+func (e *emitter) pace(ctx context.Context, p phase, tokens chan<- struct{}) {
+	defer close(tokens)
+
+	start := time.Now()
+	var pausedFor time.Duration
+	// base is the number of records sent before the last rate change.
+	base := 0
+	for sent := 0; p.Count == 0 || sent < p.Count; {
+		if rate, ok := e.takeRateChange(); ok {
+			p.Rate, p.RampTo = rate, 0
+			start, pausedFor, base = time.Now(), 0, sent
+		}
+
+		if e.paused.Load() {
+			pauseStart := time.Now()
+			if !sleep(ctx, time.Millisecond) {
+				return
+			}
+			pausedFor += time.Since(pauseStart)
+			continue
+		}
+
+		if p.throttled() {
+			elapsed := time.Since(start) - pausedFor
+			e.rate.Store(math.Float64bits(p.rateAt(elapsed)))
+			if float64(sent-base) > p.expected(elapsed) {
+				if !sleep(ctx, time.Millisecond) {
+					return
+				}
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case tokens <- struct{}{}:
+			sent++
+		}
+	}
+}
+
+// sleep waits for d and reports whether ctx is still active afterwards.
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// phaseStats holds the timing statistics of a phase.
+type phaseStats struct {
+	Name    string
+	Emitted int
+	Elapsed time.Duration
+	// Latencies of the Emit calls. With the SimpleProcessor, these include the export.
+	Min, Mean, P50, P95, P99, Max time.Duration
+}
+
+func (s *phaseStats) record(latencies []time.Duration) {
+	s.Emitted = len(latencies)
+	if len(latencies) == 0 {
+		return
+	}
+
+	slices.Sort(latencies)
+	var sum time.Duration
+	for _, l := range latencies {
+		sum += l
+	}
+	percentile := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))]
+	}
+
+	s.Min = latencies[0]
+	s.Mean = sum / time.Duration(len(latencies))
+	s.P50 = percentile(0.50)
+	s.P95 = percentile(0.95)
+	s.P99 = percentile(0.99)
+	s.Max = latencies[len(latencies)-1]
+}
+
+// rate returns the achieved rate in records per second.
+func (s phaseStats) rate() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Emitted) / s.Elapsed.Seconds()
+}
+
+func (s phaseStats) String() string {
+	return fmt.Sprintf("phase %q: %d records in %v (%.1f/s), emit latency min=%v mean=%v p50=%v p95=%v p99=%v max=%v",
+		s.Name, s.Emitted, s.Elapsed.Round(time.Millisecond), s.rate(),
+		s.Min, s.Mean, s.P50, s.P95, s.P99, s.Max)
+}
+
+// MarshalJSON encodes the durations as strings, e.g. "1.5ms".
+func (s phaseStats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"name":    s.Name,
+		"emitted": s.Emitted,
+		"elapsed": s.Elapsed.String(),
+		"rate":    s.rate(),
+		"latency": map[string]string{
+			"min":  s.Min.String(),
+			"mean": s.Mean.String(),
+			"p50":  s.P50.String(),
+			"p95":  s.P95.String(),
+			"p99":  s.P99.String(),
+			"max":  s.Max.String(),
+		},
+	})
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	olog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// sequenceKey is the attribute carrying the sequence number of a generated record.
+// The e2e verification queries the sink for this key, so don't rename it.
+const sequenceKey = "log-count"
+
+// exportResult is the outcome of the last Export call that contained a record.
+type exportResult int
+
+const (
+	exportDelivered exportResult = iota + 1
+	exportFailed
+	exportTimedOut
+)
+
+// exportReport lists the sequence numbers per export result.
+// Delivered means that the exporter returned no error, so the record is believed to be delivered
+// to the next hop (usually the collector). Anything lost after that is pipeline loss.
+type exportReport struct {
+	Delivered []int `json:"delivered"`
+	Failed    []int `json:"failed"`
+	TimedOut  []int `json:"timedOut"`
+	// Untracked counts exported records without a sequence number.
+	Untracked int `json:"untracked"`
+}
+
+// trackingExporter decorates an sdklog.Exporter and records the export result per sequence number.
+// On shutdown, it writes an exportReport to reportPath ("-" means stdout, "" disables the report).
+type trackingExporter struct {
+	sdklog.Exporter
+
+	reportPath string
+
+	mu        sync.Mutex
+	results   map[int]exportResult
+	untracked int
+}
+
+var _ sdklog.Exporter = (*trackingExporter)(nil)
+
+func newTrackingExporter(exporter sdklog.Exporter, reportPath string) *trackingExporter {
+	return &trackingExporter{
+		Exporter:   exporter,
+		reportPath: reportPath,
+		results:    make(map[int]exportResult),
+	}
+}
+
+// Export forwards the records to the wrapped exporter and records the result for each of them.
+func (e *trackingExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	err := e.Exporter.Export(ctx, records)
+
+	result := exportDelivered
+	if err != nil {
+		result = exportFailed
+		if isTimeout(ctx, err) {
+			result = exportTimedOut
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := range records {
+		seq, ok := sequenceOf(&records[i])
+		if !ok {
+			e.untracked++
+			continue
+		}
+		// A record that has been delivered once stays delivered, even if a later export of it fails.
+		if e.results[seq] != exportDelivered {
+			e.results[seq] = result
+		}
+	}
+	return err
+}
+
+// Shutdown shuts down the wrapped exporter and writes the export report afterwards,
+// so that exports finished during shutdown are part of the report.
+func (e *trackingExporter) Shutdown(ctx context.Context) error {
+	err := e.Exporter.Shutdown(ctx)
+	return errors.Join(err, e.writeReport())
+}
+
+// exportCounts holds the number of records per export result.
+type exportCounts struct {
+	Delivered int `json:"delivered"`
+	Failed    int `json:"failed"`
+	TimedOut  int `json:"timedOut"`
+	Untracked int `json:"untracked"`
+}
+
+func (e *trackingExporter) counts() exportCounts {
+	r := e.report()
+	return exportCounts{
+		Delivered: len(r.Delivered),
+		Failed:    len(r.Failed),
+		TimedOut:  len(r.TimedOut),
+		Untracked: r.Untracked,
+	}
+}
+
+// reset forgets all export results, e.g. when a new run starts with sequence number 1 again.
+func (e *trackingExporter) reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.results = make(map[int]exportResult)
+	e.untracked = 0
+}
+
+// report returns a snapshot of the export results sorted by sequence number.
+func (e *trackingExporter) report() exportReport {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	r := exportReport{
+		Delivered: []int{},
+		Failed:    []int{},
+		TimedOut:  []int{},
+		Untracked: e.untracked,
+	}
+	for seq, result := range e.results {
+		switch result {
+		case exportDelivered:
+			r.Delivered = append(r.Delivered, seq)
+		case exportFailed:
+			r.Failed = append(r.Failed, seq)
+		case exportTimedOut:
+			r.TimedOut = append(r.TimedOut, seq)
+		}
+	}
+	slices.Sort(r.Delivered)
+	slices.Sort(r.Failed)
+	slices.Sort(r.TimedOut)
+	return r
+}
+
+func (e *trackingExporter) writeReport() error {
+	if err := writeJSON(e.reportPath, e.report()); err != nil {
+		return fmt.Errorf("failed to write export report: %w", err)
+	}
+	return nil
+}
+
+// sequenceOf returns the sequence number stored in the sequenceKey attribute of the record.
+func sequenceOf(rec *sdklog.Record) (seq int, ok bool) {
+	rec.WalkAttributes(func(kv olog.KeyValue) bool {
+		if kv.Key != sequenceKey {
+			return true
+		}
+		switch kv.Value.Kind() {
+		case olog.KindInt64:
+			seq, ok = int(kv.Value.AsInt64()), true
+		case olog.KindString:
+			n, err := strconv.Atoi(kv.Value.AsString())
+			seq, ok = n, err == nil
+		}
+		return false
+	})
+	return seq, ok
+}
+
+// isTimeout reports whether an export failed because a deadline was exceeded.
+// The OTLP exporter cancels its export context with an unexported cause ("exporter export timeout"),
+// so the error text is checked as well.
+func isTimeout(ctx context.Context, err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(ctx.Err(), context.DeadlineExceeded) ||
+		status.Code(err) == codes.DeadlineExceeded ||
+		strings.Contains(err.Error(), "export timeout")
+}
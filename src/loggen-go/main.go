@@ -2,21 +2,25 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
-	"strconv"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
-	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
-	olog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/log/global"
 	sdklog "go.opentelemetry.io/otel/sdk/log"
 	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 var (
@@ -25,60 +29,161 @@ var (
 
 	cancelContextWith context.CancelCauseFunc
 
-	logger = global.GetLoggerProvider().Logger("")
+	tracer        = otel.Tracer("loggen-go")
+	logger        = global.GetLoggerProvider().Logger("")
+	exportTracker *trackingExporter
+
+	logCount     = flag.Int("count", 10, "number of log records to emit")
+	rate         = flag.Float64("rate", 100, "records per second (0 for as fast as possible)")
+	concurrency  = flag.Int("concurrency", 1, "number of goroutines emitting records")
+	serveAddr    = flag.String("serve", "", "address to serve the HTTP control API on, e.g. :8080 (runs as a daemon instead of a one-shot run)")
+	scenarioPath = flag.String("scenario", "", "YAML scenario file with phases to run, overrides -count, -rate, -template and -concurrency")
+	templateName = flag.String("template", "test", "template of the generated records: "+strings.Join(templateNames(), ", "))
+	runID        = flag.String("run-id", "", "ID of the run, attached to every record (random if empty)")
+	manifestPath = flag.String("manifest", "manifest.json", "file to write the manifest of the run to (\"-\" for stdout, \"\" to disable)")
+
+	flushTimeout     = flag.Duration("flush-timeout", 10*time.Second, "time to wait for the export of pending log records at shutdown")
+	withSpans        = flag.Bool("spans", false, "emit every record inside a span, so that its trace and span IDs are set")
+	exportReportPath = flag.String("export-report", "export-report.json", "file to write the export results per sequence number to at shutdown (\"-\" for stdout, \"\" to disable)")
 )
 
 func main() {
-	if err := run(); err != nil {
+	// Invalid flags must not exit with 2, which means 2 lost records.
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(setupErrorExitCode)
+	}
+	if err := validatePayloadFlags(); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		flag.Usage()
+		os.Exit(setupErrorExitCode)
+	}
+
+	lost, err := run()
+	if err != nil {
 		fmt.Printf("Failed to run log generator: %v\n", err)
-		log.Fatalln(err)
+		log.Println(err)
+		os.Exit(setupErrorExitCode)
+	}
+	if lost > 0 {
+		os.Exit(min(lost, maxExitCode))
 	}
 }
 
-func run() (err error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// maxExitCode caps the number of lost records reported as exit code.
+// Exit codes above 125 have special meanings in shells.
+const maxExitCode = 125
+
+// setupErrorExitCode is the exit code of invalid flags and errors that prevent a run, which is above maxExitCode,
+// so that it can't be mistaken for a number of lost records.
+const setupErrorExitCode = 126
 
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt)
+// run emits the log records and returns the number of records that may not have been exported.
+// With -verify-url, it returns the number of missing, duplicate and mutated records in the sink instead.
+func run() (lost int, err error) {
+	// Handle SIGINT (CTRL+C) and SIGTERM (sent by Kubernetes to stop a pod) gracefully.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	phases := []phase{{
+		Name:        "default",
+		Rate:        *rate,
+		Count:       *logCount,
+		Template:    *templateName,
+		Concurrency: *concurrency,
+	}}
+	if *scenarioPath != "" {
+		s, err := loadScenario(*scenarioPath)
+		if err != nil {
+			return 0, err
+		}
+		phases = s.Phases
+	} else if err := phases[0].validate(); err != nil {
+		return 0, err
+	}
+	if emitRecord, err = lookupBridge(*bridgeName); err != nil {
+		return 0, err
+	}
 
 	shutdown, err := setupOTelSDK(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to setup OpenTelemetry SDK: %v", err)
+		return 0, fmt.Errorf("failed to setup OpenTelemetry SDK: %v", err)
 	}
 
-	defer func() {
-		if err := shutdown(ctx); err != nil {
-			fmt.Printf("failed to shutdown OpenTelemetry SDK: %v", err)
-		}
-	}()
-
-	done := make(chan struct{})
-	go func() {
-		fmt.Println("Starting log emission...")
-
-		logCount := 10
-		for i := 1; i <= logCount; i++ {
-			func() {
-				rec := olog.Record{}
-				rec.SetSeverity(olog.SeverityInfo)
-				rec.SetBody(olog.StringValue("test"))
-				rec.AddAttributes(olog.KeyValueFromAttribute(attribute.String("log-count", strconv.Itoa(i))))
-				logger.Emit(context.Background(), rec)
-				time.Sleep(10 * time.Millisecond)
-			}()
-		}
-		close(done)
-	}()
+	var runManifest *manifest
+	if *serveAddr != "" {
+		// Runs are started through the control API until the server is stopped.
+		err = serve(ctx, *serveAddr)
+	} else {
+		runManifest, err = generate(ctx, phases)
+	}
+	interrupted := ctx.Err() != nil
+	// Stop receiving signal notifications, so that a second signal terminates immediately.
+	stop()
+
+	// ctx may already be canceled, so flush with a fresh deadline.
+	flushCtx, cancel := context.WithTimeout(context.Background(), *flushTimeout)
+	defer cancel()
+	if err := shutdown(flushCtx); err != nil {
+		fmt.Printf("failed to shutdown OpenTelemetry SDK: %v\n", err)
+	}
+
+	if err != nil || runManifest == nil {
+		return 0, err
+	}
+	notExported := runManifest.Count - len(exportTracker.report().Delivered)
+	if notExported > 0 {
+		log.Printf("%d log records may not have been exported", notExported)
+	}
+	if *verifyURL == "" || interrupted {
+		return notExported, nil
+	}
+
+	result, err := verify(context.Background(), runManifest)
+	if err != nil {
+		return 0, fmt.Errorf("failed to verify run: %w", err)
+	}
+	result.print()
+	if err := writeJSON(*verifyReportPath, result); err != nil {
+		fmt.Printf("failed to write verify report: %v\n", err)
+	}
+	return result.problems(), nil
+}
 
-	select {
-	case <-stop:
-		log.Println("Interrupt signal received. Shutting down...")
-	case <-done:
+// generate runs the phases once and returns the finished manifest of the run.
+func generate(ctx context.Context, phases []phase) (*manifest, error) {
+	if *runID == "" {
+		*runID = newRunID()
+	}
+	runManifest := newManifest(*runID)
+	e := newEmitter(*runID, runManifest)
+
+	fmt.Printf("Starting log emission for run %s...\n", *runID)
+	allStats, err := e.runPhases(ctx, phases)
+	runManifest.finish()
+	if err != nil {
+		return runManifest, err
+	}
+
+	if ctx.Err() != nil {
+		log.Println("Signal received. Stopped log emission. Shutting down...")
+	} else {
 		log.Println("Completed log emission. Shutting down...")
 	}
+	if len(allStats) > 1 {
+		fmt.Println("Phase summary:")
+		for _, stats := range allStats {
+			fmt.Printf("  %v\n", stats)
+		}
+	}
 
-	return nil
+	if err := runManifest.write(*manifestPath); err != nil {
+		fmt.Printf("failed to write manifest: %v\n", err)
+	}
+	return runManifest, nil
 }
 
 func setupOTelSDK(ctx context.Context) (shutdown func(context.Context) error, err error) {
@@ -102,9 +207,19 @@ func setupOTelSDK(ctx context.Context) (shutdown func(context.Context) error, er
 		handleErr(err)
 		return
 	}
-	shutdownFuncs = append(shutdownFuncs, loggerProvider.Shutdown)
+	shutdownFuncs = append(shutdownFuncs, loggerProvider.ForceFlush, loggerProvider.Shutdown)
 	global.SetLoggerProvider(loggerProvider)
 
+	if *withSpans {
+		tracerProvider, err := newTracerProvider(ctx)
+		if err != nil {
+			handleErr(err)
+			return nil, err
+		}
+		shutdownFuncs = append(shutdownFuncs, tracerProvider.ForceFlush, tracerProvider.Shutdown)
+		otel.SetTracerProvider(tracerProvider)
+	}
+
 	return shutdown, err
 }
 
@@ -114,9 +229,44 @@ func newLoggerProvider(ctx context.Context) (*sdklog.LoggerProvider, error) {
 		return nil, fmt.Errorf("failed to create gRPC exporter: %w", err)
 	}
 
+	// Track the export result of every record, so that client-side loss can be told apart from pipeline loss.
+	exportTracker = newTrackingExporter(grpcExporter, *exportReportPath)
+
 	loggerProvider := sdklog.NewLoggerProvider(
-		sdklog.WithProcessor(sdklog.NewSimpleProcessor(grpcExporter)),
+		sdklog.WithProcessor(sdklog.NewSimpleProcessor(exportTracker)),
 	)
 
 	return loggerProvider, nil
 }
+
+// newTracerProvider creates a tracer provider exporting spans via OTLP/gRPC, configured by the OTEL_EXPORTER_OTLP_* environment variables.
+func newTracerProvider(ctx context.Context) (*sdktrace.TracerProvider, error) {
+	traceExporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC trace exporter: %w", err)
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(traceExporter),
+	)
+	return tracerProvider, nil
+}
+
+// writeJSON writes v as indented JSON to path. The path "-" means stdout, an empty path writes nothing.
+func writeJSON(path string, v any) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
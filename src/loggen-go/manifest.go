@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	olog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// runIDKey is the attribute carrying the ID of the run that generated a record.
+const runIDKey = "loggen-run-id"
+
+// manifestRecord describes a single generated record.
+type manifestRecord struct {
+	Seq       int    `json:"seq"`
+	Severity  int    `json:"severity"`
+	EventName string `json:"eventName,omitempty"`
+	Hash      string `json:"hash"`
+	// TraceID and SpanID are set if the record has been emitted inside a span.
+	TraceID string `json:"traceId,omitempty"`
+	SpanID  string `json:"spanId,omitempty"`
+}
+
+// manifest is the ground truth of a run: a verifier can match every record received by the sink against it.
+type manifest struct {
+	RunID     string           `json:"runId"`
+	Count     int              `json:"count"`
+	StartTime time.Time        `json:"startTime"`
+	EndTime   time.Time        `json:"endTime"`
+	Records   []manifestRecord `json:"records"`
+
+	mu sync.Mutex
+}
+
+func newManifest(runID string) *manifest {
+	return &manifest{
+		RunID:     runID,
+		StartTime: time.Now(),
+		Records:   []manifestRecord{},
+	}
+}
+
+// add records rec, which has been emitted with sequence number seq in the span sc.
+// If rec cannot be hashed, it is recorded without a hash, so that the verifier reports it as mutated, and the error is returned.
+func (m *manifest) add(seq int, rec *olog.Record, sc trace.SpanContext) error {
+	hash, err := recordHash(rec)
+	entry := manifestRecord{
+		Seq:       seq,
+		Severity:  int(rec.Severity()),
+		EventName: rec.EventName(),
+		Hash:      hash,
+	}
+	if sc.IsValid() {
+		entry.TraceID = sc.TraceID().String()
+		entry.SpanID = sc.SpanID().String()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Records = append(m.Records, entry)
+	return err
+}
+
+// finish marks the end of the run.
+func (m *manifest) finish() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.EndTime = time.Now()
+	m.Count = len(m.Records)
+	slices.SortFunc(m.Records, func(a, b manifestRecord) int { return a.Seq - b.Seq })
+}
+
+// write writes the manifest as JSON to path ("-" means stdout, "" disables the manifest).
+func (m *manifest) write(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return writeJSON(path, m)
+}
+
+// newRunID returns a random ID for a run.
+func newRunID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// recordHash returns the SHA-256 hash of the canonical encoding of the body and attributes of rec.
+func recordHash(rec *olog.Record) (string, error) {
+	attrs := map[string]any{}
+	rec.WalkAttributes(func(kv olog.KeyValue) bool {
+		attrs[kv.Key] = canonicalValue(kv.Value)
+		return true
+	})
+	return canonicalHash(canonicalValue(rec.Body()), attrs)
+}
+
+// canonicalHash hashes a body and attributes that have been converted with canonicalValue.
+// The encoding is byte-exact: strings and bytes are hashed as their raw bytes, so invalid UTF-8 does not collapse
+// into U+FFFD, and numbers keep their type, so NaN and the infinities can be hashed as well.
+func canonicalHash(body any, attrs map[string]any) (string, error) {
+	h := sha256.New()
+	if err := writeCanonical(h, map[string]any{"body": body, "attributes": attrs}); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeCanonical writes the canonical encoding of v to w. Every value is prefixed with a type tag,
+// strings, bytes and map keys are base64 encoded and map keys are sorted, so the encoding does not depend on the attribute order.
+func writeCanonical(w io.Writer, v any) error {
+	var err error
+	switch v := v.(type) {
+	case nil:
+		_, err = io.WriteString(w, "null;")
+	case bool:
+		_, err = fmt.Fprintf(w, "bool:%t;", v)
+	case int64:
+		_, err = fmt.Fprintf(w, "int:%d;", v)
+	case float64:
+		_, err = fmt.Fprintf(w, "double:%s;", strconv.FormatFloat(v, 'g', -1, 64))
+	case json.Number:
+		// Numbers decoded without their OTLP type are integers unless they have a fraction or an exponent.
+		if n, parseErr := strconv.ParseInt(string(v), 10, 64); parseErr == nil {
+			return writeCanonical(w, n)
+		}
+		f, parseErr := strconv.ParseFloat(string(v), 64)
+		if parseErr != nil {
+			return fmt.Errorf("invalid number %q: %w", v, parseErr)
+		}
+		return writeCanonical(w, f)
+	case string:
+		_, err = fmt.Fprintf(w, "string:%s;", base64.StdEncoding.EncodeToString([]byte(v)))
+	case []byte:
+		_, err = fmt.Fprintf(w, "bytes:%s;", base64.StdEncoding.EncodeToString(v))
+	case []any:
+		if _, err = fmt.Fprintf(w, "array:%d[", len(v)); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := writeCanonical(w, item); err != nil {
+				return err
+			}
+		}
+		_, err = io.WriteString(w, "];")
+	case map[string]any:
+		if _, err = fmt.Fprintf(w, "map:%d{", len(v)); err != nil {
+			return err
+		}
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			if _, err := fmt.Fprintf(w, "%s=", base64.StdEncoding.EncodeToString([]byte(key))); err != nil {
+				return err
+			}
+			if err := writeCanonical(w, v[key]); err != nil {
+				return err
+			}
+		}
+		_, err = io.WriteString(w, "};")
+	default:
+		return fmt.Errorf("unsupported value of type %T", v)
+	}
+	return err
+}
+
+// canonicalValue converts v into plain Go values with a stable encoding.
+func canonicalValue(v olog.Value) any {
+	switch v.Kind() {
+	case olog.KindBool:
+		return v.AsBool()
+	case olog.KindInt64:
+		return v.AsInt64()
+	case olog.KindFloat64:
+		return v.AsFloat64()
+	case olog.KindString:
+		return v.AsString()
+	case olog.KindBytes:
+		return v.AsBytes()
+	case olog.KindSlice:
+		values := []any{}
+		for _, item := range v.AsSlice() {
+			values = append(values, canonicalValue(item))
+		}
+		return values
+	case olog.KindMap:
+		values := map[string]any{}
+		for _, kv := range v.AsMap() {
+			values[kv.Key] = canonicalValue(kv.Value)
+		}
+		return values
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	olog "go.opentelemetry.io/otel/log"
+)
+
+func TestCanonicalHash(t *testing.T) {
+	hash := func(t *testing.T, body any, attrs map[string]any) string {
+		t.Helper()
+		h, err := canonicalHash(body, attrs)
+		if err != nil {
+			t.Fatalf("canonicalHash() error = %v", err)
+		}
+		return h
+	}
+
+	tests := []struct {
+		name  string
+		a, b  any
+		equal bool
+	}{
+		{name: "invalid UTF-8 and replacement character", a: "\xff\xfe", b: strings.ToValidUTF8("\xff\xfe", "�")},
+		{name: "different invalid UTF-8", a: "\xff\xfe\xfd", b: "\xed\xa0\x80"},
+		{name: "int and double", a: int64(1), b: float64(1)},
+		{name: "string and bytes", a: "abc", b: []byte("abc")},
+		{name: "NaN and infinity", a: math.NaN(), b: math.Inf(1)},
+		{name: "positive and negative infinity", a: math.Inf(1), b: math.Inf(-1)},
+		{name: "large ints", a: int64(1<<53 + 1), b: int64(1 << 53)},
+		{name: "nested map and array", a: map[string]any{"a": []any{"b"}}, b: []any{map[string]any{"a": "b"}}},
+		{name: "NaN", a: math.NaN(), b: math.NaN(), equal: true},
+		{name: "map key order", a: map[string]any{"a": int64(1), "b": int64(2)}, b: map[string]any{"b": int64(2), "a": int64(1)}, equal: true},
+		{name: "integral json.Number", a: json.Number("9007199254740993"), b: int64(1<<53 + 1), equal: true},
+		{name: "fractional json.Number", a: json.Number("1.5"), b: 1.5, equal: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := hash(t, tt.a, nil), hash(t, tt.b, nil)
+			if (a == b) != tt.equal {
+				t.Errorf("hashes equal = %t, want %t", a == b, tt.equal)
+			}
+		})
+	}
+
+	t.Run("unsupported type", func(t *testing.T) {
+		if h, err := canonicalHash(struct{}{}, nil); err == nil {
+			t.Errorf("canonicalHash() = %q, want an error", h)
+		}
+	})
+}
+
+// TestRecordHashMatchesSinks checks that a record hashes the same as its decoded form in the sinks.
+func TestRecordHashMatchesSinks(t *testing.T) {
+	m := newManifest("run-1")
+	rec := olog.Record{}
+	rec.SetBody(olog.StringValue("body"))
+	rec.AddAttributes(
+		olog.String(sequenceKey, "1"),
+		olog.String(runIDKey, "run-1"),
+		olog.Int64("large", 1<<53+1),
+		olog.Float64("nan", math.NaN()),
+	)
+	want, err := recordHash(&rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("otlp-json", func(t *testing.T) {
+		var lr otlpLogRecord
+		data := `{"body":{"stringValue":"body"},"attributes":[
+			{"key":"` + sequenceKey + `","value":{"stringValue":"1"}},
+			{"key":"` + runIDKey + `","value":{"stringValue":"run-1"}},
+			{"key":"large","value":{"intValue":"9007199254740993"}},
+			{"key":"nan","value":{"doubleValue":"NaN"}}]}`
+		if err := json.Unmarshal([]byte(data), &lr); err != nil {
+			t.Fatal(err)
+		}
+		attrs := map[string]any{}
+		for i := range lr.Attributes {
+			attrs[lr.Attributes[i].Key] = lr.Attributes[i].Value.canonical()
+		}
+		got, ok, err := toReceived(m, attrs, lr.Body.canonical(), 0, "", "", "")
+		if err != nil || !ok {
+			t.Fatalf("toReceived() = %t, %v", ok, err)
+		}
+		if got.Hash != want {
+			t.Errorf("hash = %s, want %s", got.Hash, want)
+		}
+	})
+
+	t.Run("opensearch", func(t *testing.T) {
+		// OpenSearch cannot store NaN, so the document only has the integer attribute.
+		rec := olog.Record{}
+		rec.SetBody(olog.StringValue("body"))
+		rec.AddAttributes(
+			olog.String(sequenceKey, "1"),
+			olog.String(runIDKey, "run-1"),
+			olog.Int64("large", 1<<53+1),
+		)
+		want, err := recordHash(&rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var doc openSearchDocument
+		data := `{"body":"body","attributes":{"` + sequenceKey + `":"1","` + runIDKey + `":"run-1","large":9007199254740993}}`
+		dec := json.NewDecoder(strings.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			t.Fatal(err)
+		}
+		got, ok, err := toReceived(m, doc.Attributes, doc.Body, 0, "", "", "")
+		if err != nil || !ok {
+			t.Fatalf("toReceived() = %t, %v", ok, err)
+		}
+		if got.Hash != want {
+			t.Errorf("hash = %s, want %s", got.Hash, want)
+		}
+	})
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	olog "go.opentelemetry.io/otel/log"
+)
+
+// Flags of the edge-case templates, which find out where the pipeline truncates or drops data.
+var (
+	bodySize       = flag.Int("body-size", 1<<20, "body size in bytes of the large-body template")
+	nestingDepth   = flag.Int("nesting-depth", 64, "depth of the body of the nested template")
+	attributeCount = flag.Int("attribute-count", 1000, "number of attributes of the many-attributes template")
+)
+
+// validatePayloadFlags checks the flags of the edge-case templates, which would make the templates panic otherwise.
+func validatePayloadFlags() error {
+	switch {
+	case *bodySize < 0:
+		return errors.New("-body-size must not be negative")
+	case *nestingDepth < 0:
+		return errors.New("-nesting-depth must not be negative")
+	case *attributeCount < 0:
+		return errors.New("-attribute-count must not be negative")
+	}
+	return nil
+}
+
+// unusualStrings are valid UTF-8 strings that are often mishandled.
+var unusualStrings = map[string]string{
+	"emoji":          "audit \U0001f510\U0001f469\u200d\U0001f4bb\U0001f3f3\ufe0f\u200d\U0001f308 done",
+	"combining":      "Z\u0337\u0322\u031ba\u0338\u0321l, \u00e9 (NFC) vs e\u0301 (NFD)",
+	"bidi":           "\u202eexe.live\u202c \u0639\u0631\u0628\u0649 \u05e2\u05d1\u05e8\u05d9\u05ea",
+	"zero-width":     "user\u200b\u200c\u200d\ufeffname",
+	"control":        "line1\nline2\r\ttab\x00nul\x1bescape",
+	"cjk":            "\u76e3\u67fb\u30ed\u30b0 \uac10\uc0ac \ub85c\uadf8",
+	"supplementary":  "\U0001d518\U0001d52b\U0001d526 \U0002070e\U00020731",
+	"noncharacters":  "\ufffe\uffff\U0010ffff",
+	"replacement":    "\ufffd\ufffd",
+	"json-injection": `","injected":"true`,
+}
+
+// invalidUTF8Strings are not valid UTF-8. Protobuf refuses to marshal these in string fields,
+// so the export of such records is expected to fail.
+var invalidUTF8Strings = map[string]string{
+	"truncated": "abc\xe2\x82",
+	"invalid":   "\xff\xfe\xfd",
+	"surrogate": "\xed\xa0\x80",
+	"overlong":  "\xc0\xaf",
+}
+
+func edgeCaseTemplates() map[string]template {
+	return map[string]template{
+		"large-body":      largeBodyTemplate,
+		"nested":          nestedTemplate,
+		"many-attributes": manyAttributesTemplate,
+		"unicode":         stringsTemplate(unusualStrings),
+		"invalid-utf8":    stringsTemplate(invalidUTF8Strings),
+		"empty":           emptyTemplate,
+	}
+}
+
+// largeBodyTemplate emits a string body of -body-size bytes.
+func largeBodyTemplate(rec *olog.Record, seq int) {
+	rec.SetSeverity(olog.SeverityInfo)
+	rec.SetBody(olog.StringValue(strings.Repeat(fmt.Sprintf("%d|", seq%10), *bodySize/2) + strings.Repeat("x", *bodySize%2)))
+}
+
+// nestedTemplate emits a body of maps and slices nested -nesting-depth levels deep.
+func nestedTemplate(rec *olog.Record, seq int) {
+	value := olog.IntValue(seq)
+	for level := *nestingDepth; level > 0; level-- {
+		if level%2 == 0 {
+			value = olog.SliceValue(olog.IntValue(level), value)
+		} else {
+			value = olog.MapValue(olog.Int("level", level), olog.KeyValue{Key: "child", Value: value})
+		}
+	}
+	rec.SetSeverity(olog.SeverityInfo)
+	rec.SetBody(value)
+}
+
+// manyAttributesTemplate emits -attribute-count attributes. Note that the SDK drops attributes
+// beyond OTEL_LOGRECORD_ATTRIBUTE_COUNT_LIMIT (default 128).
+func manyAttributesTemplate(rec *olog.Record, seq int) {
+	attrs := make([]olog.KeyValue, 0, *attributeCount)
+	for i := range *attributeCount {
+		attrs = append(attrs, olog.String(fmt.Sprintf("attr-%05d", i), fmt.Sprintf("value-%d-%d", seq, i)))
+	}
+	rec.SetSeverity(olog.SeverityInfo)
+	rec.SetBody(olog.StringValue("many attributes"))
+	rec.AddAttributes(attrs...)
+}
+
+// stringsTemplate uses the given strings as body, attribute keys and attribute values.
+func stringsTemplate(values map[string]string) template {
+	return func(rec *olog.Record, _ int) {
+		var body []olog.KeyValue
+		var attrs []olog.KeyValue
+		for name, value := range values {
+			body = append(body, olog.String(name, value))
+			attrs = append(attrs, olog.String("str."+name, value), olog.String(value, name))
+		}
+		rec.SetSeverity(olog.SeverityInfo)
+		rec.SetBody(olog.MapValue(body...))
+		rec.AddAttributes(attrs...)
+	}
+}
+
+// emptyTemplate emits records without severity, body and attributes (apart from the sequence number and run ID).
+func emptyTemplate(*olog.Record, int) {}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// scenario describes a multi-phase load profile, e.g. ramp up, hold steady, burst, idle.
+type scenario struct {
+	Phases []phase `yaml:"phases"`
+}
+
+// phase is a part of a scenario with its own rate, duration, template and concurrency.
+type phase struct {
+	Name string `yaml:"name"`
+	// Rate is the number of records per second over all workers.
+	// 0 means as fast as possible if Count is set, and idle otherwise.
+	Rate float64 `yaml:"rate"`
+	// RampTo changes the rate linearly from Rate to RampTo over the duration of the phase.
+	RampTo float64 `yaml:"rampTo"`
+	// Duration ends the phase after the given time.
+	Duration time.Duration `yaml:"duration"`
+	// Count ends the phase after the given number of records.
+	Count       int    `yaml:"count"`
+	Template    string `yaml:"template"`
+	Concurrency int    `yaml:"concurrency"`
+}
+
+// loadScenario reads a scenario from a YAML file.
+func loadScenario(path string) (*scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+	s, err := parseScenario(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// parseScenario parses and validates a scenario in YAML (or JSON) format.
+func parseScenario(data []byte) (*scenario, error) {
+	var s scenario
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if err := s.normalize(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// normalize sets defaults for the phases and validates them.
+func (s *scenario) normalize() error {
+	if len(s.Phases) == 0 {
+		return errors.New("no phases")
+	}
+	for i := range s.Phases {
+		p := &s.Phases[i]
+		if p.Name == "" {
+			p.Name = fmt.Sprintf("phase-%d", i+1)
+		}
+		if p.Template == "" {
+			p.Template = "test"
+		}
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("invalid phase %q: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+func (p phase) validate() error {
+	if p.Duration <= 0 && p.Count <= 0 {
+		return errors.New("either duration or count must be set")
+	}
+	if p.Rate < 0 || p.RampTo < 0 || p.Count < 0 || p.Concurrency < 0 {
+		return errors.New("rate, rampTo, count and concurrency must not be negative")
+	}
+	if p.RampTo > 0 && p.Duration <= 0 {
+		return errors.New("rampTo requires a duration")
+	}
+	_, err := lookupTemplate(p.Template)
+	return err
+}
+
+// idle reports whether the phase emits no records at all.
+func (p phase) idle() bool {
+	return p.Rate == 0 && p.RampTo == 0 && p.Count == 0
+}
+
+// throttled reports whether the phase emits at a given rate instead of as fast as possible.
+func (p phase) throttled() bool {
+	return p.Rate > 0 || p.RampTo > 0
+}
+
+// rateAt returns the target rate after elapsed time.
+func (p phase) rateAt(elapsed time.Duration) float64 {
+	if p.RampTo == 0 || p.Duration <= 0 {
+		return p.Rate
+	}
+	return p.Rate + (p.RampTo-p.Rate)*min(elapsed.Seconds()/p.Duration.Seconds(), 1)
+}
+
+// expected returns the number of records that should have been emitted after elapsed time.
+func (p phase) expected(elapsed time.Duration) float64 {
+	t := elapsed.Seconds()
+	if p.RampTo == 0 || p.Duration <= 0 {
+		return p.Rate * t
+	}
+	// Integral of the linearly changing rate.
+	return p.Rate*t + (p.RampTo-p.Rate)*t*t/(2*p.Duration.Seconds())
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// States of a generation.
+const (
+	stateRunning   = "running"
+	stateStopped   = "stopped"
+	stateCompleted = "completed"
+	stateFailed    = "failed"
+)
+
+// generation is a run started through the control API.
+type generation struct {
+	runID    string
+	emitter  *emitter
+	manifest *manifest
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	mu        sync.Mutex
+	state     string
+	err       error
+	startTime time.Time
+	endTime   time.Time
+}
+
+// generationStatus is the progress of a generation returned by the control API.
+type generationStatus struct {
+	RunID     string     `json:"runId"`
+	State     string     `json:"state"`
+	Error     string     `json:"error,omitempty"`
+	StartTime time.Time  `json:"startTime"`
+	EndTime   *time.Time `json:"endTime,omitempty"`
+	progress
+	Export exportCounts `json:"export"`
+}
+
+func (g *generation) status() generationStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	st := generationStatus{
+		RunID:     g.runID,
+		State:     g.state,
+		StartTime: g.startTime,
+		progress:  g.emitter.progress(),
+		Export:    exportTracker.counts(),
+	}
+	if g.err != nil {
+		st.Error = g.err.Error()
+	}
+	if !g.endTime.IsZero() {
+		st.EndTime = &g.endTime
+	}
+	return st
+}
+
+// startRequest is the body of a request to start a generation.
+// It either describes a single phase inline or a list of phases like a scenario file.
+type startRequest struct {
+	RunID  string `yaml:"runId"`
+	phase  `yaml:",inline"`
+	Phases []phase `yaml:"phases"`
+}
+
+// controlServer drives generation runs through an HTTP API, so that a test orchestrator can run many
+// experiments against one deployment without recreating pods. Only one generation runs at a time.
+type controlServer struct {
+	// ctx is canceled when the server shuts down, which stops the current generation.
+	ctx context.Context
+
+	mu      sync.Mutex
+	current *generation
+}
+
+// serve runs the control API on addr until ctx is canceled.
+func serve(ctx context.Context, addr string) error {
+	s := &controlServer{ctx: ctx}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /runs", s.start)
+	mux.HandleFunc("GET /runs/current", s.status)
+	mux.HandleFunc("GET /runs/current/manifest", s.currentManifest)
+	mux.HandleFunc("POST /runs/current/stop", s.stop)
+	mux.HandleFunc("POST /runs/current/pause", s.pause)
+	mux.HandleFunc("POST /runs/current/resume", s.resume)
+	mux.HandleFunc("PUT /runs/current/rate", s.setRate)
+
+	srv := &http.Server{
+		Addr:         addr,
+		BaseContext:  func(_ net.Listener) context.Context { return ctx },
+		ReadTimeout:  time.Second,
+		WriteTimeout: 10 * time.Second,
+		Handler:      mux,
+	}
+	srvErr := make(chan error, 1)
+	go func() {
+		log.Printf("Control API listening on %s", addr)
+		srvErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-srvErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	err := srv.Shutdown(context.Background())
+	if g := s.generation(); g != nil {
+		<-g.done
+	}
+	return err
+}
+
+func (s *controlServer) generation() *generation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current
+}
+
+// start starts a new generation, unless one is still running.
+func (s *controlServer) start(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req startRequest
+	if err := yaml.Unmarshal(body, &req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	sc := scenario{Phases: req.Phases}
+	if len(sc.Phases) == 0 {
+		sc.Phases = []phase{req.phase}
+	}
+	if err := sc.normalize(); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.RunID == "" {
+		req.RunID = newRunID()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != nil && s.current.status().State == stateRunning {
+		http.Error(w, fmt.Sprintf("run %s is still running", s.current.runID), http.StatusConflict)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	m := newManifest(req.RunID)
+	g := &generation{
+		runID:     req.RunID,
+		emitter:   newEmitter(req.RunID, m),
+		manifest:  m,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		state:     stateRunning,
+		startTime: time.Now(),
+	}
+	// The sequence numbers of the new run start at 1 again.
+	exportTracker.reset()
+	s.current = g
+
+	go func() {
+		defer close(g.done)
+		defer cancel()
+		log.Printf("Starting log emission for run %s...", g.runID)
+		_, err := g.emitter.runPhases(ctx, sc.Phases)
+
+		m.finish()
+		if err := m.write(*manifestPath); err != nil {
+			log.Printf("failed to write manifest: %v", err)
+		}
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.endTime = time.Now()
+		switch {
+		case err != nil:
+			g.state, g.err = stateFailed, err
+		case ctx.Err() != nil:
+			g.state = stateStopped
+		default:
+			g.state = stateCompleted
+		}
+		log.Printf("Run %s %s", g.runID, g.state)
+	}()
+
+	writeStatus(w, http.StatusAccepted, g.status())
+}
+
+func (s *controlServer) status(w http.ResponseWriter, _ *http.Request) {
+	g := s.generation()
+	if g == nil {
+		http.Error(w, "no run has been started", http.StatusNotFound)
+		return
+	}
+	writeStatus(w, http.StatusOK, g.status())
+}
+
+// currentManifest returns the manifest of the current run, once it has ended.
+func (s *controlServer) currentManifest(w http.ResponseWriter, _ *http.Request) {
+	g := s.generation()
+	if g == nil {
+		http.Error(w, "no run has been started", http.StatusNotFound)
+		return
+	}
+	select {
+	case <-g.done:
+	default:
+		http.Error(w, fmt.Sprintf("run %s is still running", g.runID), http.StatusConflict)
+		return
+	}
+	g.manifest.mu.Lock()
+	defer g.manifest.mu.Unlock()
+	writeStatus(w, http.StatusOK, g.manifest)
+}
+
+// stop stops the current generation and waits until it has ended.
+func (s *controlServer) stop(w http.ResponseWriter, r *http.Request) {
+	g := s.generation()
+	if g == nil {
+		http.Error(w, "no run has been started", http.StatusNotFound)
+		return
+	}
+	g.cancel()
+	select {
+	case <-g.done:
+	case <-r.Context().Done():
+		return
+	}
+	writeStatus(w, http.StatusOK, g.status())
+}
+
+func (s *controlServer) pause(w http.ResponseWriter, _ *http.Request) {
+	s.control(w, (*emitter).pause)
+}
+
+func (s *controlServer) resume(w http.ResponseWriter, _ *http.Request) {
+	s.control(w, (*emitter).resume)
+}
+
+// setRate changes the rate of the current phase, e.g. {"rate": 50}.
+// The rate must be positive, as the emission is paused with POST /runs/current/pause.
+func (s *controlServer) setRate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Rate *float64 `json:"rate"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Rate == nil || *req.Rate <= 0 {
+		http.Error(w, `invalid request, expected {"rate": <records per second greater than 0>}`, http.StatusBadRequest)
+		return
+	}
+	s.control(w, func(e *emitter) { e.setRate(*req.Rate) })
+}
+
+// control applies fn to the emitter of the running generation.
+func (s *controlServer) control(w http.ResponseWriter, fn func(*emitter)) {
+	g := s.generation()
+	if g == nil || g.status().State != stateRunning {
+		http.Error(w, "no run is running", http.StatusConflict)
+		return
+	}
+	fn(g.emitter)
+	writeStatus(w, http.StatusOK, g.status())
+}
+
+func writeStatus(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Write failed: %v\n", err)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strings"
+
+	olog "go.opentelemetry.io/otel/log"
+)
+
+// Attribute keys of generated audit events.
+const (
+	actorKey         = "audit.actor"
+	actionKey        = "audit.action"
+	targetKey        = "audit.target"
+	outcomeKey       = "audit.outcome"
+	sourceAddressKey = "client.address"
+)
+
+// template fills the severity, event name, body and attributes of the record with sequence number seq.
+// The sequence number and run ID attributes are added by the emitter, not by the template.
+type template func(rec *olog.Record, seq int)
+
+// auditProfile describes a kind of audit event.
+type auditProfile struct {
+	event    string
+	action   string
+	outcome  string
+	severity olog.Severity
+	// bodySize is the approximate size of the body in bytes.
+	bodySize int
+	// targets are the resources the action is performed on.
+	targets []string
+	// message is formatted with the actor, target and source address.
+	message string
+}
+
+var auditProfiles = map[string]auditProfile{
+	"login-success": {
+		event:    "user.login",
+		action:   "login",
+		outcome:  "success",
+		severity: olog.SeverityInfo,
+		bodySize: 256,
+		targets:  []string{"web-console", "api-gateway", "ssh-bastion"},
+		message:  "user %s logged in to %s from %s",
+	},
+	"login-failure": {
+		event:    "user.login",
+		action:   "login",
+		outcome:  "failure",
+		severity: olog.SeverityWarn,
+		bodySize: 256,
+		targets:  []string{"web-console", "api-gateway", "ssh-bastion"},
+		message:  "user %s failed to log in to %s from %s: invalid credentials",
+	},
+	"permission-change": {
+		event:    "iam.permission.change",
+		action:   "grant-role",
+		outcome:  "success",
+		severity: olog.SeverityWarn,
+		bodySize: 512,
+		targets:  []string{"role/admin", "role/auditor", "role/developer", "group/operators"},
+		message:  "user %s changed the permissions of %s from %s",
+	},
+	"data-export": {
+		event:    "data.export",
+		action:   "export",
+		outcome:  "success",
+		severity: olog.SeverityInfo,
+		bodySize: 2048,
+		targets:  []string{"db/customers", "db/orders", "bucket/invoices"},
+		message:  "user %s exported %s from %s",
+	},
+	"config-update": {
+		event:    "config.update",
+		action:   "update",
+		outcome:  "success",
+		severity: olog.SeverityInfo2,
+		bodySize: 1024,
+		targets:  []string{"config/payment-service", "config/feature-flags", "config/otel-collector"},
+		message:  "user %s updated %s from %s",
+	},
+}
+
+var templates = newTemplates()
+
+func newTemplates() map[string]template {
+	t := map[string]template{
+		"test":      testTemplate,
+		"audit-mix": auditMixTemplate,
+	}
+	for name, profile := range auditProfiles {
+		t[name] = profile.fill
+	}
+	maps.Copy(t, edgeCaseTemplates())
+	return t
+}
+
+// templateNames returns the sorted names of all templates, e.g. for flag usage.
+func templateNames() []string {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// lookupTemplate returns the template with the given name.
+func lookupTemplate(name string) (template, error) {
+	tmpl, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown template %q, use one of: %s", name, strings.Join(templateNames(), ", "))
+	}
+	return tmpl, nil
+}
+
+// testTemplate is the original loggen-go record: an info record with body "test".
+func testTemplate(rec *olog.Record, _ int) {
+	rec.SetSeverity(olog.SeverityInfo)
+	rec.SetBody(olog.StringValue("test"))
+}
+
+// auditMixTemplate cycles through all audit profiles.
+func auditMixTemplate(rec *olog.Record, seq int) {
+	names := make([]string, 0, len(auditProfiles))
+	for name := range auditProfiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	auditProfiles[names[seq%len(names)]].fill(rec, seq)
+}
+
+// fill generates an audit event of this profile. The random values are seeded with seq,
+// so the same sequence number always results in the same record.
+func (p auditProfile) fill(rec *olog.Record, seq int) {
+	rnd := rand.New(rand.NewPCG(uint64(seq), 0))
+
+	actor := fmt.Sprintf("user-%03d", rnd.IntN(1000))
+	target := p.targets[rnd.IntN(len(p.targets))]
+	sourceAddress := fmt.Sprintf("10.%d.%d.%d", rnd.IntN(256), rnd.IntN(256), 1+rnd.IntN(254))
+
+	rec.SetSeverity(p.severity)
+	rec.SetSeverityText(p.severity.String())
+	rec.SetEventName(p.event)
+	rec.SetBody(olog.StringValue(padBody(fmt.Sprintf(p.message, actor, target, sourceAddress), p.bodySize, rnd)))
+	rec.AddAttributes(
+		olog.String(actorKey, actor),
+		olog.String(actionKey, p.action),
+		olog.String(targetKey, target),
+		olog.String(outcomeKey, p.outcome),
+		olog.String(sourceAddressKey, sourceAddress),
+	)
+}
+
+// padBody appends request details to msg until it has roughly size bytes.
+func padBody(msg string, size int, rnd *rand.Rand) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; b.Len() < size; i++ {
+		fmt.Fprintf(&b, "; detail-%d=%016x", i, rnd.Uint64())
+	}
+	return b.String()
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Flags of the post-run verification.
+var (
+	verifyURL        = flag.String("verify-url", "", "URL to query the received records from after the run, e.g. http://log-sink-nginx:8080/received_logs.json (verification is skipped if empty)")
+	verifyFormat     = flag.String("verify-format", "otlp-json", "format of the verify URL: otlp-json (file exporter output) or opensearch (_search endpoint)")
+	verifyTimeout    = flag.Duration("verify-timeout", time.Minute, "time to wait for all records of the run to show up")
+	verifyInterval   = flag.Duration("verify-interval", 2*time.Second, "interval between queries of the verify URL")
+	verifyReportPath = flag.String("verify-report", "verify-report.json", "file to write the verification result to (\"-\" for stdout, \"\" to disable)")
+)
+
+// receivedRecord is a record of the run as found in the sink.
+type receivedRecord struct {
+	Seq       int
+	Severity  int
+	EventName string
+	TraceID   string
+	SpanID    string
+	Hash      string
+}
+
+// mutation is a field of a received record that differs from the manifest.
+type mutation struct {
+	Seq      int    `json:"seq"`
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Received string `json:"received"`
+}
+
+// verification is the result of matching the received records against the manifest.
+type verification struct {
+	RunID      string     `json:"runId"`
+	Expected   int        `json:"expected"`
+	Received   int        `json:"received"`
+	Missing    []int      `json:"missing"`
+	Duplicates []int      `json:"duplicates"`
+	Mutated    []mutation `json:"mutated"`
+}
+
+// problems returns the number of missing, duplicate and mutated records.
+func (v verification) problems() int {
+	return len(v.Missing) + len(v.Duplicates) + len(v.Mutated)
+}
+
+func (v verification) print() {
+	fmt.Printf("Verified run %s: %d of %d records received, %d missing, %d duplicates, %d mutated fields\n",
+		v.RunID, v.Received, v.Expected, len(v.Missing), len(v.Duplicates), len(v.Mutated))
+	for _, m := range v.Mutated {
+		if m.Field == "hash" {
+			fmt.Printf("  record %d: body or attributes changed\n", m.Seq)
+			continue
+		}
+		fmt.Printf("  record %d: %s %q, expected %q\n", m.Seq, m.Field, m.Received, m.Expected)
+	}
+}
+
+// verify polls the verify URL until all records of the manifest have been received or the timeout has elapsed.
+func verify(ctx context.Context, m *manifest) (verification, error) {
+	fetch, ok := fetchers[*verifyFormat]
+	if !ok {
+		return verification{}, fmt.Errorf("unknown verify format %q", *verifyFormat)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *verifyTimeout)
+	defer cancel()
+
+	fmt.Printf("Verifying run %s against %s...\n", m.RunID, *verifyURL)
+	for {
+		received, err := fetch(ctx, *verifyURL, m)
+		if err != nil {
+			fmt.Printf("failed to query received records: %v\n", err)
+		}
+		result := match(m, received)
+		if err == nil && len(result.Missing) == 0 {
+			return result, nil
+		}
+		if !sleep(ctx, *verifyInterval) {
+			if err != nil {
+				return result, err
+			}
+			return result, nil
+		}
+	}
+}
+
+// match compares the received records with the manifest.
+func match(m *manifest, received []receivedRecord) verification {
+	v := verification{
+		RunID:      m.RunID,
+		Expected:   len(m.Records),
+		Received:   len(received),
+		Missing:    []int{},
+		Duplicates: []int{},
+		Mutated:    []mutation{},
+	}
+
+	bySeq := map[int][]receivedRecord{}
+	for _, r := range received {
+		bySeq[r.Seq] = append(bySeq[r.Seq], r)
+	}
+
+	for _, want := range m.Records {
+		got, ok := bySeq[want.Seq]
+		if !ok {
+			v.Missing = append(v.Missing, want.Seq)
+			continue
+		}
+		if len(got) > 1 {
+			v.Duplicates = append(v.Duplicates, want.Seq)
+		}
+		for _, r := range got {
+			v.Mutated = append(v.Mutated, compare(want, r)...)
+		}
+	}
+	return v
+}
+
+func compare(want manifestRecord, got receivedRecord) []mutation {
+	var mutations []mutation
+	check := func(field, expected, received string) {
+		if expected != received {
+			mutations = append(mutations, mutation{Seq: want.Seq, Field: field, Expected: expected, Received: received})
+		}
+	}
+	check("severity", strconv.Itoa(want.Severity), strconv.Itoa(got.Severity))
+	check("eventName", want.EventName, got.EventName)
+	check("traceId", want.TraceID, got.TraceID)
+	check("spanId", want.SpanID, got.SpanID)
+	check("hash", want.Hash, got.Hash)
+	return mutations
+}
+
+// fetcher queries the records of the run described by m from a sink.
+type fetcher func(ctx context.Context, url string, m *manifest) ([]receivedRecord, error)
+
+var fetchers = map[string]fetcher{
+	"otlp-json":  fetchOTLPJSON,
+	"opensearch": fetchOpenSearch,
+}
+
+// otlpAnyValue is an AnyValue in the OTLP JSON encoding.
+type otlpAnyValue struct {
+	StringValue *string      `json:"stringValue"`
+	BoolValue   *bool        `json:"boolValue"`
+	IntValue    *json.Number `json:"intValue"`
+	DoubleValue *otlpDouble  `json:"doubleValue"`
+	BytesValue  []byte       `json:"bytesValue"`
+	ArrayValue  *struct {
+		Values []otlpAnyValue `json:"values"`
+	} `json:"arrayValue"`
+	KvlistValue *struct {
+		Values []otlpKeyValue `json:"values"`
+	} `json:"kvlistValue"`
+}
+
+// otlpDouble is a double in the OTLP JSON encoding, which writes NaN and the infinities as strings.
+type otlpDouble float64
+
+func (d *otlpDouble) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) != nil {
+		return json.Unmarshal(data, (*float64)(d))
+	}
+	switch s {
+	case "NaN":
+		*d = otlpDouble(math.NaN())
+	case "Infinity":
+		*d = otlpDouble(math.Inf(1))
+	case "-Infinity":
+		*d = otlpDouble(math.Inf(-1))
+	default:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid double %q", s)
+		}
+		*d = otlpDouble(f)
+	}
+	return nil
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpLogRecord struct {
+	SeverityNumber int            `json:"severityNumber"`
+	EventName      string         `json:"eventName"`
+	Body           *otlpAnyValue  `json:"body"`
+	Attributes     []otlpKeyValue `json:"attributes"`
+	TraceID        string         `json:"traceId"`
+	SpanID         string         `json:"spanId"`
+}
+
+// otlpLogsData is an ExportLogsServiceRequest in the OTLP JSON encoding, as written by the file exporter of the collector.
+type otlpLogsData struct {
+	ResourceLogs []struct {
+		ScopeLogs []struct {
+			LogRecords []otlpLogRecord `json:"logRecords"`
+		} `json:"scopeLogs"`
+	} `json:"resourceLogs"`
+}
+
+// canonical converts v into the same plain Go values as canonicalValue.
+func (v *otlpAnyValue) canonical() any {
+	switch {
+	case v == nil:
+		return nil
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.IntValue != nil:
+		n, _ := v.IntValue.Int64()
+		return n
+	case v.DoubleValue != nil:
+		return float64(*v.DoubleValue)
+	case v.BytesValue != nil:
+		return v.BytesValue
+	case v.ArrayValue != nil:
+		values := []any{}
+		for i := range v.ArrayValue.Values {
+			values = append(values, v.ArrayValue.Values[i].canonical())
+		}
+		return values
+	case v.KvlistValue != nil:
+		values := map[string]any{}
+		for i := range v.KvlistValue.Values {
+			values[v.KvlistValue.Values[i].Key] = v.KvlistValue.Values[i].Value.canonical()
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// fetchOTLPJSON reads the output of the file exporter, which is a stream of OTLP JSON documents.
+func fetchOTLPJSON(ctx context.Context, url string, m *manifest) ([]receivedRecord, error) {
+	body, err := httpDo(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var received []receivedRecord
+	dec := json.NewDecoder(bytes.NewReader(body))
+	for {
+		var data otlpLogsData
+		if err := dec.Decode(&data); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return received, fmt.Errorf("failed to decode OTLP JSON: %w", err)
+		}
+		for _, rl := range data.ResourceLogs {
+			for _, sl := range rl.ScopeLogs {
+				for _, lr := range sl.LogRecords {
+					attrs := map[string]any{}
+					for i := range lr.Attributes {
+						attrs[lr.Attributes[i].Key] = lr.Attributes[i].Value.canonical()
+					}
+					r, ok, err := toReceived(m, attrs, lr.Body.canonical(), lr.SeverityNumber, lr.EventName, lr.TraceID, lr.SpanID)
+					if err != nil {
+						return received, err
+					}
+					if ok {
+						received = append(received, r)
+					}
+				}
+			}
+		}
+	}
+	return received, nil
+}
+
+// openSearchDocument is a log record as stored by the OpenSearch exporter of the collector.
+type openSearchDocument struct {
+	Body       any            `json:"body"`
+	Attributes map[string]any `json:"attributes"`
+	Severity   struct {
+		Number int `json:"number"`
+	} `json:"severity"`
+	EventName string `json:"eventName"`
+	TraceID   string `json:"traceId"`
+	SpanID    string `json:"spanId"`
+}
+
+// fetchOpenSearch queries the records of the run from an OpenSearch _search endpoint.
+// Runs with more than 5000 records exceed the default maximum result window of OpenSearch.
+func fetchOpenSearch(ctx context.Context, url string, m *manifest) ([]receivedRecord, error) {
+	query, err := json.Marshal(map[string]any{
+		"size": min(2*len(m.Records)+10, 10000),
+		"query": map[string]any{
+			"term": map[string]any{"attributes." + runIDKey: m.RunID},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	body, err := httpDo(ctx, http.MethodPost, url, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Hits struct {
+			Hits []struct {
+				Source openSearchDocument `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	// Numbers are decoded as json.Number, as float64 cannot represent all int64 attributes.
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode OpenSearch response: %w", err)
+	}
+
+	var received []receivedRecord
+	for _, hit := range result.Hits.Hits {
+		doc := hit.Source
+		if doc.Attributes == nil {
+			doc.Attributes = map[string]any{}
+		}
+		r, ok, err := toReceived(m, doc.Attributes, doc.Body, doc.Severity.Number, doc.EventName, doc.TraceID, doc.SpanID)
+		if err != nil {
+			return received, err
+		}
+		if ok {
+			received = append(received, r)
+		}
+	}
+	return received, nil
+}
+
+// toReceived converts the fields of a record found in the sink. Records of other runs are skipped.
+func toReceived(m *manifest, attrs map[string]any, body any, severity int, eventName, traceID, spanID string) (receivedRecord, bool, error) {
+	if attrs[runIDKey] != m.RunID {
+		return receivedRecord{}, false, nil
+	}
+	seqValue, _ := attrs[sequenceKey].(string)
+	seq, err := strconv.Atoi(seqValue)
+	if err != nil {
+		return receivedRecord{}, false, nil
+	}
+	hash, err := canonicalHash(body, attrs)
+	if err != nil {
+		return receivedRecord{}, false, fmt.Errorf("failed to hash record %d: %w", seq, err)
+	}
+	return receivedRecord{
+		Seq:       seq,
+		Severity:  severity,
+		EventName: eventName,
+		TraceID:   traceID,
+		SpanID:    spanID,
+		Hash:      hash,
+	}, true, nil
+}
+
+func httpDo(ctx context.Context, method, url string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s from %s", resp.Status, url)
+	}
+	return data, nil
+}